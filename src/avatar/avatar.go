@@ -0,0 +1,184 @@
+package avatar
+
+import (
+	"bytes"
+	"chat-app-back/src/config"
+	"chat-app-back/src/models"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+)
+
+// The square sizes, in pixels, generated for every avatar. Largest first.
+var Sizes = []int{256, 128, 64, 32}
+
+// The size linked from profile_picture.
+const DefaultSize = 256
+
+const MaxUploadBytes = 5 << 20
+
+// Larger images are rejected before decoding so a small file can't expand into a huge bitmap.
+const maxSourcePixels = 16_000_000
+
+var (
+	ErrUnsupportedImage = errors.New("image must be a PNG, JPEG or GIF")
+	ErrImageTooLarge    = errors.New("image is too large")
+)
+
+func ValidSize(size int) bool {
+	for _, s := range Sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// Storage key of one size of an uploaded avatar.
+func Key(userID string, version string, size int) string {
+	return fmt.Sprintf("avatars/%s/%s/%d.png", userID, version, size)
+}
+
+// URL of one size of an uploaded avatar. Every upload gets a new version, so the files
+// behind a URL never change.
+func URL(userID string, version string, size int) string {
+	return config.PublicAPIURL(fmt.Sprintf("/api/avatars/%s/%s/%d", userID, version, size))
+}
+
+func IdenticonURL(userID string, size int) string {
+	return config.PublicAPIURL(fmt.Sprintf("/api/identicons/%s/%d", userID, size))
+}
+
+// Returns the URL of the picture the user's avatar source points to.
+func ProfilePictureURL(user models.User) string {
+	uid := user.ID.Hex()
+
+	switch user.AvatarSource {
+	case models.AvatarSourceCustom:
+		if user.AvatarVersion != "" {
+			return URL(uid, user.AvatarVersion, DefaultSize)
+		}
+	case models.AvatarSourceIdenticon:
+		return IdenticonURL(uid, DefaultSize)
+	}
+
+	if user.ProviderPicture != nil && *user.ProviderPicture != "" {
+		return *user.ProviderPicture
+	}
+	return IdenticonURL(uid, DefaultSize)
+}
+
+// Checks the size of an upload and decodes it.
+func decode(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxUploadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxUploadBytes {
+		return nil, ErrImageTooLarge
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width*config.Height > maxSourcePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil || source.Bounds().Empty() {
+		return nil, ErrUnsupportedImage
+	}
+	return source, nil
+}
+
+// Crops the largest centered area with the given aspect ratio out of the image.
+func crop(source image.Image, ratioWidth int, ratioHeight int) *image.RGBA {
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width*ratioHeight > height*ratioWidth {
+		width = max(height*ratioWidth/ratioHeight, 1)
+	} else {
+		height = max(width*ratioHeight/ratioWidth, 1)
+	}
+
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-width)/2, bounds.Min.Y+(bounds.Dy()-height)/2)
+	cropped := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(cropped, cropped.Bounds(), source, origin, draw.Src)
+	return cropped
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Decodes an uploaded image, crops it to a centered square and encodes it as a PNG
+// in each of the avatar sizes.
+func Process(r io.Reader) (map[int][]byte, error) {
+	source, err := decode(r)
+	if err != nil {
+		return nil, err
+	}
+	square := crop(source, 1, 1)
+
+	// Each size is scaled down from the one before it
+	images := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		square = resize(square, size, size)
+
+		images[size], err = encode(square)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return images, nil
+}
+
+// Scales an image to width by height by averaging the source pixels that fall into
+// each target pixel. Smaller images are scaled up by repeating pixels.
+func resize(source *image.RGBA, width int, height int) *image.RGBA {
+	sourceWidth, sourceHeight := source.Bounds().Dx(), source.Bounds().Dy()
+	if sourceWidth == width && sourceHeight == height {
+		return source
+	}
+
+	target := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := y * sourceHeight / height
+		y1 := max((y+1)*sourceHeight/height, y0+1)
+
+		for x := 0; x < width; x++ {
+			x0 := x * sourceWidth / width
+			x1 := max((x+1)*sourceWidth/width, x0+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := source.Pix[sy*source.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for i := 0; i < 4; i++ {
+						sum[i] += int(row[sx*4+i])
+					}
+				}
+			}
+
+			count := (y1 - y0) * (x1 - x0)
+			offset := y*target.Stride + x*4
+			for i := 0; i < 4; i++ {
+				target.Pix[offset+i] = uint8(sum[i] / count)
+			}
+		}
+	}
+
+	return target
+}
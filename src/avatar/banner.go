@@ -0,0 +1,32 @@
+package avatar
+
+import (
+	"chat-app-back/src/config"
+	"fmt"
+	"io"
+)
+
+// Profile banners are 3:1 and stored in a single size.
+const (
+	BannerWidth  = 1500
+	BannerHeight = 500
+)
+
+func BannerKey(userID string, version string) string {
+	return fmt.Sprintf("banners/%s/%s.png", userID, version)
+}
+
+func BannerURL(userID string, version string) string {
+	return config.PublicAPIURL(fmt.Sprintf("/api/banners/%s/%s", userID, version))
+}
+
+// Decodes an uploaded image, crops it to a centered 3:1 area and encodes it as a
+// PNG of the banner size.
+func ProcessBanner(r io.Reader) ([]byte, error) {
+	source, err := decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return encode(resize(crop(source, BannerWidth, BannerHeight), BannerWidth, BannerHeight))
+}
@@ -0,0 +1,89 @@
+package avatar
+
+import (
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+const identiconCells = 5
+
+var identiconBackground = color.RGBA{R: 240, G: 240, B: 240, A: 255}
+
+// Generates a symmetric 5x5 pattern in a color derived from the seed, so a user
+// without a picture always gets the same recognizable image.
+func Identicon(seed string, size int) *image.RGBA {
+	sum := sha256.Sum256([]byte(seed))
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: identiconBackground}, image.Point{}, draw.Src)
+
+	foreground := &image.Uniform{C: identiconColor(sum[0], sum[1])}
+	cell := size / (identiconCells + 1)
+	margin := (size - cell*identiconCells) / 2
+
+	// Only the left half and the middle column are random, the right half mirrors them
+	for row := 0; row < identiconCells; row++ {
+		for column := 0; column < (identiconCells+1)/2; column++ {
+			bit := row*3 + column
+			if sum[2+bit/8]&(1<<(bit%8)) == 0 {
+				continue
+			}
+
+			for _, x := range []int{column, identiconCells - 1 - column} {
+				rect := image.Rect(margin+x*cell, margin+row*cell, margin+(x+1)*cell, margin+(row+1)*cell)
+				draw.Draw(img, rect, foreground, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	return img
+}
+
+// Encodes the identicon for the seed as a PNG.
+func IdenticonPNG(seed string, size int) ([]byte, error) {
+	return encode(Identicon(seed, size))
+}
+
+// Picks a saturated, mid-lightness color from two bytes of the hash.
+func identiconColor(hueByte byte, lightnessByte byte) color.RGBA {
+	hue := float64(hueByte) / 256 * 360
+	lightness := 0.45 + float64(lightnessByte%20)/100
+	saturation := 0.6
+
+	chroma := (1 - abs(2*lightness-1)) * saturation
+	h := hue / 60
+	x := chroma * (1 - abs(mod2(h)-1))
+
+	var r, g, b float64
+	switch {
+	case h < 1:
+		r, g = chroma, x
+	case h < 2:
+		r, g = x, chroma
+	case h < 3:
+		g, b = chroma, x
+	case h < 4:
+		g, b = x, chroma
+	case h < 5:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+
+	m := lightness - chroma/2
+	return color.RGBA{R: uint8((r + m) * 255), G: uint8((g + m) * 255), B: uint8((b + m) * 255), A: 255}
+}
+
+func abs(f float64) float64 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
+
+// Returns f modulo 2 for non-negative f.
+func mod2(f float64) float64 {
+	return f - 2*float64(int(f/2))
+}
@@ -0,0 +1,55 @@
+// Command create-admin makes an existing user the first admin. Further admins are
+// appointed through the admin endpoints, so it refuses to run once an admin exists.
+//
+//	go run ./src/cmd/create-admin -email someone@example.com
+package main
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/models"
+	"context"
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the user to make admin")
+	flag.Parse()
+
+	if *email == "" {
+		log.Fatal("-email is required")
+	}
+
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	users := config.MongoClient().Database("Chat-App").Collection("users")
+
+	count, err := users.CountDocuments(ctx, bson.M{"role": models.RoleAdmin})
+	if err != nil {
+		log.Fatal("error counting admins:", err)
+	}
+	if count > 0 {
+		log.Fatal("an admin already exists, use the admin endpoints to add more")
+	}
+
+	result, err := users.UpdateOne(ctx, bson.M{"email": strings.ToLower(strings.TrimSpace(*email))}, bson.M{"$set": bson.M{"role": models.RoleAdmin}})
+	if err != nil {
+		log.Fatal("error updating user:", err)
+	}
+	if result.MatchedCount == 0 {
+		log.Fatalf("no user with email %s, register the account first", *email)
+	}
+
+	log.Printf("%s is now an admin, the role applies from their next token refresh", *email)
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"chat-app-back/src/identity"
+	"context"
+	"log"
+	"os"
+	"sync"
+)
+
+var identityVerifier identity.IdentityVerifier
+var identityOnce sync.Once
+
+// Returns the verifier for account tokens, chosen by IDENTITY_PROVIDER:
+// "firebase" (the default), "oidc" or "fake".
+func IdentityVerifier() identity.IdentityVerifier {
+	identityOnce.Do(func() {
+		provider := os.Getenv("IDENTITY_PROVIDER")
+		switch provider {
+		case "", "firebase":
+			verifier, err := identity.NewFirebaseVerifier(context.Background(), InitializeApp())
+			if err != nil {
+				log.Fatal("error initializing firebase identity verifier:", err)
+			}
+			identityVerifier = verifier
+		case "oidc":
+			verifier, err := identity.NewOIDCVerifier(context.Background(), os.Getenv("OIDC_ISSUER"), os.Getenv("OIDC_CLIENT_ID"))
+			if err != nil {
+				log.Fatal("error initializing oidc identity verifier:", err)
+			}
+			identityVerifier = verifier
+		case "fake":
+			secret := os.Getenv("FAKE_IDENTITY_SECRET")
+			if secret == "" {
+				log.Fatal("FAKE_IDENTITY_SECRET is required for the fake identity provider")
+			}
+			identityVerifier = identity.NewFakeVerifier(secret)
+		default:
+			log.Fatalf("unknown identity provider %q", provider)
+		}
+	})
+
+	return identityVerifier
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Creates the indexes the handlers rely on. CreateMany is a no-op for indexes that already exist.
+func EnsureIndexes() {
+	db := MongoClient().Database("Chat-App")
+
+	c, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	indexes := map[string][]mongo.IndexModel{
+		"messages": {
+			{
+				Keys:    bson.D{{Key: "content", Value: "text"}},
+				Options: options.Index().SetName("content_text").SetDefaultLanguage("english"),
+			},
+			{Keys: bson.D{{Key: "channel_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			{Keys: bson.D{{Key: "sender_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+		},
+		"users": {
+			{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetName("email_unique").SetUnique(true).SetSparse(true)},
+			{
+				Keys:    bson.D{{Key: "identity_provider", Value: 1}, {Key: "identity_subject", Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"identity_subject": bson.M{"$exists": true}}),
+			},
+			{Keys: bson.D{{Key: "username_key", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+			{Keys: bson.D{{Key: "owner_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "deletion_scheduled_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "custom_status.expires_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "display_name_terms", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "username_search_key", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "username_key", Value: 1}}},
+		},
+		"api_keys": {
+			{Keys: bson.D{{Key: "key_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "bot_id", Value: 1}}},
+		},
+		"blocks": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "target_id", Value: 1}, {Key: "kind", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "kind", Value: 1}}},
+		},
+		"friendships": {
+			{Keys: bson.D{{Key: "pair_key", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "user_ids", Value: 1}, {Key: "status", Value: 1}}},
+		},
+		"username_history": {
+			{Keys: bson.D{{Key: "username_key", Value: 1}, {Key: "changed_at", Value: -1}}},
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		},
+		"drafts": {
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "channel_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		"email_tokens": {
+			{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}}},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"mfa_challenges": {
+			{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"refresh_tokens": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_used_at", Value: -1}}},
+			{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			{Keys: bson.D{{Key: "family_id", Value: 1}}},
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "client_id", Value: 1}}},
+		},
+		"oauth_clients": {
+			{Keys: bson.D{{Key: "client_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "owner_id", Value: 1}}},
+		},
+		"oauth_codes": {
+			{Keys: bson.D{{Key: "code_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"oauth_grants": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "client_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		"security_events": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		"scheduled_messages": {
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "send_at", Value: 1}}},
+			{Keys: bson.D{{Key: "sender_id", Value: 1}, {Key: "status", Value: 1}, {Key: "send_at", Value: 1}}},
+		},
+	}
+
+	// Indexes replaced by one with different options, which can't share their key
+	obsolete := map[string][]string{
+		"users": {"email_1"},
+	}
+	for collection, names := range obsolete {
+		for _, name := range names {
+			_, err := db.Collection(collection).Indexes().DropOne(c, name)
+			var commandErr mongo.CommandError
+			if errors.As(err, &commandErr) && (commandErr.Name == "IndexNotFound" || commandErr.Name == "NamespaceNotFound") {
+				continue
+			}
+			if err != nil {
+				log.Fatalf("error dropping index %s of %s: %v", name, collection, err)
+			}
+		}
+	}
+
+	for collection, models := range indexes {
+		_, err := db.Collection(collection).Indexes().CreateMany(c, models)
+		if err != nil {
+			log.Fatalf("error creating indexes for %s: %v", collection, err)
+		}
+	}
+}
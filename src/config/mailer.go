@@ -0,0 +1,33 @@
+package config
+
+import (
+	"chat-app-back/src/mailer"
+	"log"
+	"os"
+	"sync"
+)
+
+var mailerInstance mailer.Mailer
+var mailerOnce sync.Once
+
+// Returns the mailer chosen by MAILER: "log" (the default) or "smtp".
+func Mailer() mailer.Mailer {
+	mailerOnce.Do(func() {
+		kind := os.Getenv("MAILER")
+		switch kind {
+		case "", "log":
+			mailerInstance = mailer.LogMailer{Dir: os.Getenv("MAIL_DIR")}
+		case "smtp":
+			mailerInstance = mailer.SMTPMailer{
+				Addr:     os.Getenv("SMTP_ADDR"),
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+				From:     os.Getenv("MAIL_FROM"),
+			}
+		default:
+			log.Fatalf("unknown mailer %q", kind)
+		}
+	})
+
+	return mailerInstance
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+var publicAPIURL string
+var publicAPIURLOnce sync.Once
+
+// Loads API_URL, exiting when it is not set. Called at startup, because the URLs built
+// from it are stored, and a wrong base would be written into every one of them.
+func RequirePublicAPIURL() {
+	publicAPIURLOnce.Do(func() {
+		base := os.Getenv("API_URL")
+		if base == "" {
+			log.Fatal("API_URL is required")
+		}
+		publicAPIURL = strings.TrimSuffix(base, "/")
+	})
+}
+
+// Returns the absolute URL of an API path, based on API_URL. Links the API hands out,
+// such as avatar URLs, are opened from the client's origin, so they can't be relative.
+func PublicAPIURL(path string) string {
+	RequirePublicAPIURL()
+
+	return publicAPIURL + path
+}
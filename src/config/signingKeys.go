@@ -0,0 +1,31 @@
+package config
+
+import (
+	"chat-app-back/src/signing"
+	"log"
+	"os"
+	"sync"
+)
+
+var signingKeys *signing.KeySet
+var signingKeysOnce sync.Once
+
+// Returns the keys tokens are signed with, loaded from the PEM files in
+// JWT_KEYS_DIR (default "keys"). JWT_ACTIVE_KEY_ID picks the key new tokens are
+// signed with; the other keys are only used to verify existing tokens.
+func SigningKeys() *signing.KeySet {
+	signingKeysOnce.Do(func() {
+		dir := os.Getenv("JWT_KEYS_DIR")
+		if dir == "" {
+			dir = "keys"
+		}
+
+		keySet, err := signing.LoadKeySet(dir, os.Getenv("JWT_ACTIVE_KEY_ID"))
+		if err != nil {
+			log.Fatal("error loading signing keys:", err)
+		}
+		signingKeys = keySet
+	})
+
+	return signingKeys
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Stores uploaded files such as message attachments by key.
+type FileStorage interface {
+	Save(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// Keeps files on the local disk under Dir.
+type LocalStorage struct {
+	Dir string
+}
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+func (s LocalStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if key == "" || strings.Contains(key, "..") || cleaned == "/" {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(s.Dir, cleaned), nil
+}
+
+func (s LocalStorage) Save(key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, r)
+	return err
+}
+
+func (s LocalStorage) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
+}
+
+// Deletes the file. Deleting a file that does not exist is not an error.
+func (s LocalStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+var storage FileStorage
+var storageOnce sync.Once
+
+func Storage() FileStorage {
+	storageOnce.Do(func() {
+		dir := os.Getenv("UPLOADS_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+
+		storage = LocalStorage{Dir: dir}
+	})
+
+	return storage
+}
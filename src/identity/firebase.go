@@ -0,0 +1,35 @@
+package identity
+
+import (
+	"context"
+
+	firebase "firebase.google.com/go/v4"
+	"firebase.google.com/go/v4/auth"
+)
+
+// Verifies Firebase ID tokens, such as the ones issued for Google sign-in.
+type FirebaseVerifier struct {
+	client *auth.Client
+}
+
+func NewFirebaseVerifier(ctx context.Context, app *firebase.App) (*FirebaseVerifier, error) {
+	client, err := app.Auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FirebaseVerifier{client: client}, nil
+}
+
+func (v *FirebaseVerifier) Name() string {
+	return "firebase"
+}
+
+func (v *FirebaseVerifier) Verify(ctx context.Context, token string) (*Identity, error) {
+	verified, err := v.client.VerifyIDToken(ctx, token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return identityFromClaims(verified.UID, verified.Claims)
+}
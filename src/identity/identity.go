@@ -0,0 +1,43 @@
+package identity
+
+import (
+	"context"
+	"errors"
+)
+
+// The verified identity behind an account token.
+type Identity struct {
+	Subject string
+	Email   string
+	Picture string
+}
+
+// Verifies account tokens issued by an identity provider.
+type IdentityVerifier interface {
+	// Name of the provider, stored on the accounts it creates.
+	Name() string
+
+	// Checks the token and returns the identity it was issued for. The returned
+	// email is always verified by the provider.
+	Verify(ctx context.Context, token string) (*Identity, error)
+}
+
+var (
+	ErrInvalidToken     = errors.New("invalid account token")
+	ErrEmailNotVerified = errors.New("account email is not verified")
+)
+
+// Builds an Identity from token claims, checking the email is present and verified.
+func identityFromClaims(subject string, claims map[string]interface{}) (*Identity, error) {
+	email, _ := claims["email"].(string)
+	if subject == "" || email == "" {
+		return nil, ErrInvalidToken
+	}
+
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		return nil, ErrEmailNotVerified
+	}
+
+	picture, _ := claims["picture"].(string)
+	return &Identity{Subject: subject, Email: email, Picture: picture}, nil
+}
@@ -0,0 +1,14 @@
+package mailer
+
+import "context"
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Delivers transactional emails such as verification and password reset links.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
@@ -0,0 +1,32 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// Sends emails through an SMTP server using PLAIN auth.
+type SMTPMailer struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) Send(ctx context.Context, message Message) error {
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return err
+	}
+
+	// Header values must not carry new lines
+	sanitize := strings.NewReplacer("\r", "", "\n", "")
+	content := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		sanitize.Replace(m.From), sanitize.Replace(message.To), sanitize.Replace(message.Subject), message.Body)
+
+	auth := smtp.PlainAuth("", m.Username, m.Password, host)
+	return smtp.SendMail(m.Addr, auth, m.From, []string{message.To}, []byte(content))
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/migrations"
 	routes "chat-app-back/src/routes"
 	apiRoute "chat-app-back/src/routes/api"
+	"chat-app-back/src/util"
+	"context"
 	"log"
 
+	// Profile timezones are validated against the embedded database
+	_ "time/tzdata"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -18,6 +25,32 @@ func main() {
 		log.Fatal("Error loading .env file")
 	}
 
+	// Refresh tokens are stored hashed with this key, so it has to be set
+	util.RequireRefreshTokenHashKey()
+
+	// Avatar and banner URLs are stored with the public API URL as their base
+	config.RequirePublicAPIURL()
+
+	// Load the token signing keys now, so bad keys stop the server before it serves requests
+	config.SigningKeys()
+
+	// Likewise the identity verifier, which for OIDC runs discovery and fetches the JWKS
+	config.IdentityVerifier()
+
+	// Make sure the collections are indexed before serving requests
+	config.EnsureIndexes()
+	migrations.RunMigrations()
+
+	// Background jobs
+	go apiRoute.StartMessageScheduler(context.Background())
+	go apiRoute.StartMessageReaper(context.Background())
+	go apiRoute.StartAccountDeletionReaper(context.Background())
+	go apiRoute.StartStatusReaper(context.Background())
+
+	// Presence changes are sent to clients in batches
+	util.OnPresenceChange = apiRoute.QueuePresenceChange
+	go apiRoute.StartPresenceFeed(context.Background())
+
 	// Setup routes
 	router := gin.Default()
 
@@ -25,7 +58,7 @@ func main() {
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
 	config.AllowMethods = []string{"GET", "POST"}
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-Device-Name"}
 	router.Use(cors.New(config))
 
 	// Api routes
@@ -33,6 +66,21 @@ func main() {
 	{
 		apiRoute.MessageRoutes(api)
 		apiRoute.ProfileRoutes(api)
+		apiRoute.AvatarRoutes(api)
+		apiRoute.SearchRoutes(api)
+		apiRoute.DirectoryRoutes(api)
+		apiRoute.ScheduledMessageRoutes(api)
+		apiRoute.EphemeralMessageRoutes(api)
+		apiRoute.PollRoutes(api)
+		apiRoute.DraftRoutes(api)
+		apiRoute.TwoFactorRoutes(api)
+		apiRoute.SessionRoutes(api)
+		apiRoute.BotRoutes(api)
+		apiRoute.AccountRoutes(api)
+		apiRoute.AdminRoutes(api)
+		apiRoute.FriendRoutes(api)
+		apiRoute.BlockRoutes(api)
+		apiRoute.RealtimeRoutes(api)
 	}
 
 	// Authentication routes
@@ -41,6 +89,18 @@ func main() {
 		routes.AuthenticationRoutes(auth)
 	}
 
+	// OAuth2 authorization server for third-party apps
+	oauth := router.Group("/oauth")
+	{
+		routes.OAuthRoutes(oauth)
+	}
+
+	// Public keys for verifying our tokens
+	wellKnown := router.Group("/.well-known")
+	{
+		routes.WellKnownRoutes(wellKnown)
+	}
+
 	// Run server
 	router.Run(addr)
 }
@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Looks up a bot's API key and returns claims for the bot limited to the key's scopes.
+// It responds and returns nil when the key is unknown, revoked, or its bot or the bot's
+// owner is disabled.
+func authenticateAPIKey(c *gin.Context, key string) *util.TokenClaims {
+	db := config.MongoClient()
+
+	var apiKey models.APIKey
+	err := db.Database("Chat-App").Collection("api_keys").FindOneAndUpdate(c, bson.M{"key_hash": util.HashToken(key)},
+		bson.M{"$set": bson.M{"last_used_at": primitive.NewDateTimeFromTime(time.Now())}}).Decode(&apiKey)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Invalid API key"})
+		return nil
+	}
+
+	botID, err := primitive.ObjectIDFromHex(apiKey.BotID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Invalid API key"})
+		return nil
+	}
+	var bot models.User
+	err = db.Database("Chat-App").Collection("users").FindOne(c, bson.M{"_id": botID, "bot": true}).Decode(&bot)
+	if err != nil || bot.Disabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Invalid API key"})
+		return nil
+	}
+
+	// Bots stop working along with the account that owns them
+	ownerID, err := primitive.ObjectIDFromHex(bot.OwnerID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Invalid API key"})
+		return nil
+	}
+	var owner models.User
+	err = db.Database("Chat-App").Collection("users").FindOne(c, bson.M{"_id": ownerID}).Decode(&owner)
+	if err != nil || owner.Disabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Invalid API key"})
+		return nil
+	}
+
+	return &util.TokenClaims{
+		UID:       apiKey.BotID,
+		TokenType: util.TokenTypeAPIKey,
+		Scopes:    apiKey.Scopes,
+	}
+}
@@ -1,15 +1,23 @@
 package middlewares
 
 import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
 	"net/http"
-	"os"
+	"slices"
 	"strings"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func AuthenticateAccessToken() gin.HandlerFunc {
+// Authenticates the request with an access token, or with a bot's API key. Scoped
+// credentials such as API keys are only accepted when they have one of the given
+// scopes, so endpoints that list no scopes are limited to first-party logins.
+func AuthenticateAccessToken(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 
@@ -19,31 +27,28 @@ func AuthenticateAccessToken() gin.HandlerFunc {
 			return
 		}
 
-		// Split the header to remove Bearer
-		tokenString := strings.Split(authHeader, " ")[1]
-
-		// Get token secret from env file
-		tokenSecret := os.Getenv("ACCESS_TOKEN_KEY")
-
-		// Initialize instance of Claims
-		claims := &jwt.MapClaims{}
-
-		// Attempt to parse the token
-		parsedToken, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(tokenSecret), nil
-		})
+		// Remove the Bearer scheme from the header
+		scheme, tokenString, found := strings.Cut(authHeader, " ")
+		tokenString = strings.TrimSpace(tokenString)
+		if !found || !strings.EqualFold(scheme, "Bearer") || tokenString == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Invalid authorization header"})
+			c.Abort()
+			return
+		}
 
-		// Check if token was parsed
-		if err != nil || !parsedToken.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Invalid token"})
+		var claims *util.TokenClaims
+		if util.IsAPIKey(tokenString) {
+			claims = authenticateAPIKey(c, tokenString)
+		} else {
+			claims = authenticateSession(c, tokenString)
+		}
+		if claims == nil {
 			c.Abort()
 			return
 		}
 
-		// Get uid from claims
-		uid := (*claims)["uid"].(string)
-		if uid == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Invalid token"})
+		if claims.Scoped() && !slices.ContainsFunc(scopes, claims.HasScope) {
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": "Insufficient scope"})
 			c.Abort()
 			return
 		}
@@ -53,3 +58,49 @@ func AuthenticateAccessToken() gin.HandlerFunc {
 		c.Next() // Call next handler
 	}
 }
+
+// Verifies an access token and checks that its session has not been revoked.
+// It responds and returns nil when the token is not accepted.
+func authenticateSession(c *gin.Context, tokenString string) *util.TokenClaims {
+	db := config.MongoClient()
+
+	// Verify the token with the key named by its kid, rejecting refresh tokens
+	claims, err := util.ParseToken(tokenString, util.TokenTypeAccess)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Invalid token"})
+		return nil
+	}
+
+	// Make sure the session the token was issued for has not been revoked
+	sessionID, err := primitive.ObjectIDFromHex(claims.SessionID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Invalid token"})
+		return nil
+	}
+	count, err := db.Database("Chat-App").Collection("refresh_tokens").CountDocuments(c, bson.M{"family_id": sessionID, "user_id": claims.UID, "rotated_at": bson.M{"$exists": false}}, options.Count().SetLimit(1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Error checking session"})
+		return nil
+	}
+	if count == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Session revoked"})
+		return nil
+	}
+
+	return claims
+}
+
+// Only lets through users with at least the given role. It reads the role from the
+// access token claims, so it must run after AuthenticateAccessToken.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := util.GetClaims(c)
+		if claims == nil || !models.HasRole(claims.Role, role) {
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,315 @@
+package migrations
+
+import (
+	"chat-app-back/src/avatar"
+	"chat-app-back/src/config"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"context"
+	"log"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// A one-time data migration. Applied migrations are recorded by name in the
+// migrations collection so each runs once.
+type migration struct {
+	name string
+	run  func(ctx context.Context, db *mongo.Database) error
+}
+
+var migrations = []migration{
+	{
+		// Sessions created before token families are their own family
+		name: "refresh_token_family_ids",
+		run: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("refresh_tokens").UpdateMany(ctx,
+				bson.M{"family_id": bson.M{"$exists": false}},
+				mongo.Pipeline{bson.D{{Key: "$set", Value: bson.M{"family_id": "$_id"}}}})
+			return err
+		},
+	},
+	{
+		// Replace raw refresh tokens with their keyed hash
+		name: "hash_refresh_tokens",
+		run: func(ctx context.Context, db *mongo.Database) error {
+			collection := db.Collection("refresh_tokens")
+			cursor, err := collection.Find(ctx, bson.M{"refresh_token": bson.M{"$exists": true}},
+				options.Find().SetProjection(bson.M{"refresh_token": 1}))
+			if err != nil {
+				return err
+			}
+			defer cursor.Close(ctx)
+
+			for cursor.Next(ctx) {
+				var row struct {
+					ID           primitive.ObjectID `bson:"_id"`
+					RefreshToken string             `bson:"refresh_token"`
+				}
+				if err := cursor.Decode(&row); err != nil {
+					return err
+				}
+
+				_, err := collection.UpdateOne(ctx, bson.M{"_id": row.ID}, bson.M{
+					"$set":   bson.M{"token_hash": util.HashRefreshToken(row.RefreshToken)},
+					"$unset": bson.M{"refresh_token": ""},
+				})
+				if err != nil {
+					return err
+				}
+			}
+			if err := cursor.Err(); err != nil {
+				return err
+			}
+
+			// The index on the raw token is no longer used
+			collection.Indexes().DropOne(ctx, "refresh_token_1")
+			return nil
+		},
+	},
+	{
+		// Give existing users the key that makes usernames unique. Names that collide
+		// with one taken earlier get a suffix from the user id.
+		name: "username_keys",
+		run: func(ctx context.Context, db *mongo.Database) error {
+			collection := db.Collection("users")
+			cursor, err := collection.Find(ctx, bson.M{"username_key": bson.M{"$exists": false}},
+				options.Find().SetProjection(bson.M{"username": 1}).SetSort(bson.M{"_id": 1}))
+			if err != nil {
+				return err
+			}
+			defer cursor.Close(ctx)
+
+			for cursor.Next(ctx) {
+				var row struct {
+					ID       primitive.ObjectID `bson:"_id"`
+					Username string             `bson:"username"`
+				}
+				if err := cursor.Decode(&row); err != nil {
+					return err
+				}
+
+				id := row.ID.Hex()
+				candidates := []string{row.Username, row.Username + "_" + id[len(id)-4:], row.Username + "_" + id}
+				for i, username := range candidates {
+					_, err := collection.UpdateOne(ctx, bson.M{"_id": row.ID}, bson.M{"$set": bson.M{
+						"username":     username,
+						"username_key": util.UsernameKey(username),
+					}})
+					if mongo.IsDuplicateKeyError(err) && i < len(candidates)-1 {
+						continue
+					}
+					if err != nil {
+						return err
+					}
+					if i > 0 {
+						log.Printf("renamed user %s from %q to %q: username already taken", id, row.Username, username)
+					}
+					break
+				}
+			}
+			return cursor.Err()
+		},
+	},
+	{
+		// Pictures set at login were the provider's photo. Users without one get an identicon.
+		name: "avatar_sources",
+		run: func(ctx context.Context, db *mongo.Database) error {
+			collection := db.Collection("users")
+			cursor, err := collection.Find(ctx, bson.M{"avatar_source": bson.M{"$exists": false}, "provider_picture": bson.M{"$exists": false}})
+			if err != nil {
+				return err
+			}
+			defer cursor.Close(ctx)
+
+			for cursor.Next(ctx) {
+				var user models.User
+				if err := cursor.Decode(&user); err != nil {
+					return err
+				}
+
+				set := bson.M{}
+				if user.ProfilePicture != nil && *user.ProfilePicture != "" {
+					user.ProviderPicture = user.ProfilePicture
+					set["provider_picture"] = *user.ProfilePicture
+				}
+				set["profile_picture"] = avatar.ProfilePictureURL(user)
+
+				if _, err := collection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": set}); err != nil {
+					return err
+				}
+			}
+			return cursor.Err()
+		},
+	},
+	{
+		// Custom statuses used to be plain strings
+		name: "structured_custom_status",
+		run: func(ctx context.Context, db *mongo.Database) error {
+			collection := db.Collection("users")
+			_, err := collection.UpdateMany(ctx,
+				bson.M{"custom_status": bson.M{"$in": bson.A{"", nil}}},
+				bson.M{"$unset": bson.M{"custom_status": ""}})
+			if err != nil {
+				return err
+			}
+
+			_, err = collection.UpdateMany(ctx,
+				bson.M{"custom_status": bson.M{"$type": "string"}},
+				mongo.Pipeline{bson.D{{Key: "$set", Value: bson.M{"custom_status": bson.M{"text": "$custom_status"}}}}})
+			return err
+		},
+	},
+	{
+		// Avatar and identicon links used to be stored relative to the API
+		name: "absolute_avatar_urls",
+		run: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("users").UpdateMany(ctx,
+				bson.M{"profile_picture": bson.M{"$regex": "^/api/"}},
+				mongo.Pipeline{bson.D{{Key: "$set", Value: bson.M{"profile_picture": bson.M{
+					"$concat": bson.A{config.PublicAPIURL(""), "$profile_picture"},
+				}}}}})
+			return err
+		},
+	},
+	{
+		// Give existing users the key the directory matches username prefixes against
+		name: "username_search_keys",
+		run: func(ctx context.Context, db *mongo.Database) error {
+			collection := db.Collection("users")
+			cursor, err := collection.Find(ctx, bson.M{"username": bson.M{"$exists": true}, "username_search_key": bson.M{"$exists": false}},
+				options.Find().SetProjection(bson.M{"username": 1}))
+			if err != nil {
+				return err
+			}
+			defer cursor.Close(ctx)
+
+			for cursor.Next(ctx) {
+				var row struct {
+					ID       primitive.ObjectID `bson:"_id"`
+					Username string             `bson:"username"`
+				}
+				if err := cursor.Decode(&row); err != nil {
+					return err
+				}
+
+				_, err := collection.UpdateOne(ctx, bson.M{"_id": row.ID},
+					bson.M{"$set": bson.M{"username_search_key": util.UsernameSearchKey(row.Username)}})
+				if err != nil {
+					return err
+				}
+			}
+			return cursor.Err()
+		},
+	},
+	{
+		// Drafts were stamped with the client's clock, which could be ahead of the server's
+		name: "clamp_future_drafts",
+		run: func(ctx context.Context, db *mongo.Database) error {
+			now := primitive.NewDateTimeFromTime(time.Now())
+			_, err := db.Collection("drafts").UpdateMany(ctx,
+				bson.M{"updated_at": bson.M{"$gt": now}},
+				bson.M{"$set": bson.M{"updated_at": now}})
+			return err
+		},
+	},
+	{
+		// Provider accounts stored the email as the provider sent it. One that collides
+		// with an account already using the normalized address is left as it is.
+		name: "normalize_provider_emails",
+		run: func(ctx context.Context, db *mongo.Database) error {
+			collection := db.Collection("users")
+			cursor, err := collection.Find(ctx, bson.M{"email": bson.M{"$exists": true}, "password_hash": bson.M{"$exists": false}},
+				options.Find().SetProjection(bson.M{"email": 1}))
+			if err != nil {
+				return err
+			}
+			defer cursor.Close(ctx)
+
+			for cursor.Next(ctx) {
+				var row struct {
+					ID    primitive.ObjectID `bson:"_id"`
+					Email string             `bson:"email"`
+				}
+				if err := cursor.Decode(&row); err != nil {
+					return err
+				}
+
+				email := util.NormalizeEmail(row.Email)
+				if email == row.Email {
+					continue
+				}
+				_, err := collection.UpdateOne(ctx, bson.M{"_id": row.ID}, bson.M{"$set": bson.M{"email": email}})
+				if mongo.IsDuplicateKeyError(err) {
+					log.Printf("kept email %q of user %s: %q is already used", row.Email, row.ID.Hex(), email)
+					continue
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return cursor.Err()
+		},
+	},
+	{
+		// Bots that sent messages were set offline 15 minutes later
+		name: "bots_online",
+		run: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("users").UpdateMany(ctx,
+				bson.M{"bot": true, "status": bson.M{"$ne": models.StatusOnline}},
+				bson.M{"$set": bson.M{"status": models.StatusOnline}})
+			return err
+		},
+	},
+	{
+		// absolute_avatar_urls fell back to http://localhost:8080 when API_URL was not set
+		name: "repair_default_avatar_urls",
+		run: func(ctx context.Context, db *mongo.Database) error {
+			const fallback = "http://localhost:8080"
+			if config.PublicAPIURL("") == fallback {
+				return nil
+			}
+
+			_, err := db.Collection("users").UpdateMany(ctx,
+				bson.M{"profile_picture": bson.M{"$regex": "^" + regexp.QuoteMeta(fallback+"/api/")}},
+				mongo.Pipeline{bson.D{{Key: "$set", Value: bson.M{"profile_picture": bson.M{
+					"$concat": bson.A{config.PublicAPIURL(""), bson.M{"$substrCP": bson.A{"$profile_picture", len(fallback), 1 << 20}}},
+				}}}}})
+			return err
+		},
+	},
+}
+
+// Runs the migrations that have not been applied yet. The record is inserted before
+// running, so when several instances start together only one of them runs each migration.
+func RunMigrations() {
+	db := config.MongoClient().Database("Chat-App")
+
+	for _, m := range migrations {
+		c, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+
+		_, err := db.Collection("migrations").InsertOne(c, bson.M{"_id": m.name, "applied_at": time.Now()})
+		if mongo.IsDuplicateKeyError(err) {
+			cancel()
+			continue
+		}
+		if err != nil {
+			cancel()
+			log.Fatalf("error recording migration %s: %v", m.name, err)
+		}
+
+		if err := m.run(c, db); err != nil {
+			db.Collection("migrations").DeleteOne(context.Background(), bson.M{"_id": m.name})
+			cancel()
+			log.Fatalf("error running migration %s: %v", m.name, err)
+		}
+
+		cancel()
+		log.Println("applied migration", m.name)
+	}
+}
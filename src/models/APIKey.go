@@ -0,0 +1,24 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Scopes limit what a credential other than a first-party login may do.
+const (
+	ScopeMessagesRead  = "messages:read"
+	ScopeMessagesWrite = "messages:write"
+	ScopeProfileRead   = "profile:read"
+)
+
+// A long-lived key a bot authenticates with. Only a hash of the key is stored;
+// Prefix keeps the start of the key so owners can tell their keys apart.
+type APIKey struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty"`
+	BotID      string              `bson:"bot_id"`
+	OwnerID    string              `bson:"owner_id"`
+	Name       string              `bson:"name"`
+	Prefix     string              `bson:"prefix"`
+	KeyHash    string              `bson:"key_hash"`
+	Scopes     []string            `bson:"scopes"`
+	CreatedAt  primitive.DateTime  `bson:"created_at"`
+	LastUsedAt *primitive.DateTime `bson:"last_used_at,omitempty"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	BlockKindBlock = "block"
+	BlockKindMute  = "mute"
+)
+
+// A user blocking or muting another. A block hides the target's messages and presence
+// from the user and silences their mentions. A mute only silences notifications.
+type Block struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    string             `bson:"user_id"`
+	TargetID  string             `bson:"target_id"`
+	Kind      string             `bson:"kind"`
+	CreatedAt primitive.DateTime `bson:"created_at"`
+}
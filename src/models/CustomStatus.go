@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A status a user sets for themselves, cleared automatically once ExpiresAt passes.
+type CustomStatus struct {
+	Emoji     string              `bson:"emoji,omitempty" json:"emoji,omitempty"`
+	Text      string              `bson:"text,omitempty" json:"text,omitempty"`
+	ExpiresAt *primitive.DateTime `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+}
+
+// Custom statuses used to be plain strings. Those decode as a status with just text,
+// so users who haven't been migrated yet can still be read.
+func (s *CustomStatus) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bson.RawValue{Type: t, Value: data}
+	if text, ok := value.StringValueOK(); ok {
+		*s = CustomStatus{Text: text}
+		return nil
+	}
+
+	type plain CustomStatus
+	return value.Unmarshal((*plain)(s))
+}
+
+// Returns the status unless it has expired. Expired statuses are cleared in the
+// background, so they can briefly outlive their expiry.
+func (s *CustomStatus) Active(now time.Time) *CustomStatus {
+	if s == nil || (s.ExpiresAt != nil && !s.ExpiresAt.Time().After(now)) {
+		return nil
+	}
+	return s
+}
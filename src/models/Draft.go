@@ -0,0 +1,11 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type Draft struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    string             `bson:"user_id"`
+	ChannelID string             `bson:"channel_id"`
+	Content   string             `bson:"content"`
+	UpdatedAt primitive.DateTime `bson:"updated_at"`
+}
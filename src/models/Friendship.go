@@ -0,0 +1,21 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	FriendshipPending  = "pending"
+	FriendshipAccepted = "accepted"
+)
+
+// A friend request, which becomes the friendship once accepted. Each pair of users has
+// at most one, found by PairKey.
+type Friendship struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty"`
+	PairKey     string              `bson:"pair_key"`
+	UserIDs     []string            `bson:"user_ids"`
+	RequesterID string              `bson:"requester_id"`
+	AddresseeID string              `bson:"addressee_id"`
+	Status      string              `bson:"status"`
+	CreatedAt   primitive.DateTime  `bson:"created_at"`
+	AcceptedAt  *primitive.DateTime `bson:"accepted_at,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// A login that passed the first factor and is waiting for a second factor code.
+type MFAChallenge struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    string             `bson:"user_id"`
+	TokenHash string             `bson:"token_hash"`
+	Attempts  int                `bson:"attempts"`
+	CreatedAt primitive.DateTime `bson:"created_at"`
+	ExpiresAt primitive.DateTime `bson:"expires_at"`
+}
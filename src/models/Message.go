@@ -2,9 +2,33 @@ package models
 
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
+type Attachment struct {
+	Key         string `bson:"key" json:"key"`
+	Name        string `bson:"name" json:"name"`
+	ContentType string `bson:"content_type" json:"content_type"`
+	Size        int64  `bson:"size" json:"size"`
+	URL         string `bson:"url" json:"url"`
+}
+
+const (
+	MessageTypeText = "text"
+	MessageTypePoll = "poll"
+)
+
 type Message struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	SenderID  string             `bson:"sender_id"`
-	Content   string             `bson:"content"`
-	CreatedAt primitive.DateTime `bson:"created_at"`
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	SenderID    string             `bson:"sender_id"`
+	ChannelID   string             `bson:"channel_id,omitempty"`
+	Type        string             `bson:"type,omitempty"`
+	Content     string             `bson:"content"`
+	Poll        *Poll              `bson:"poll,omitempty"`
+	Mentions    []string           `bson:"mentions,omitempty"`
+	Attachments []Attachment       `bson:"attachments,omitempty"`
+	CreatedAt   primitive.DateTime `bson:"created_at"`
+
+	// Ephemeral messages are deleted once ExpiresAt passes. Messages that expire
+	// after being read get an ExpiresAt once every recipient is in ReadBy.
+	ExpiresAt       *primitive.DateTime `bson:"expires_at,omitempty"`
+	ExpireAfterRead bool                `bson:"expire_after_read,omitempty"`
+	ReadBy          []string            `bson:"read_by,omitempty"`
 }
@@ -0,0 +1,39 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// A third-party app registered for the OAuth2 authorization code flow. Public
+// clients have no secret and rely on PKCE alone.
+type OAuthClient struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	ClientID     string             `bson:"client_id"`
+	SecretHash   string             `bson:"secret_hash,omitempty"`
+	OwnerID      string             `bson:"owner_id"`
+	Name         string             `bson:"name"`
+	RedirectURIs []string           `bson:"redirect_uris"`
+	Scopes       []string           `bson:"scopes"`
+	CreatedAt    primitive.DateTime `bson:"created_at"`
+}
+
+// A single use authorization code waiting to be exchanged for tokens.
+type OAuthCode struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	CodeHash      string             `bson:"code_hash"`
+	ClientID      string             `bson:"client_id"`
+	UserID        string             `bson:"user_id"`
+	RedirectURI   string             `bson:"redirect_uri"`
+	Scopes        []string           `bson:"scopes"`
+	CodeChallenge string             `bson:"code_challenge"`
+	CreatedAt     primitive.DateTime `bson:"created_at"`
+	ExpiresAt     primitive.DateTime `bson:"expires_at"`
+}
+
+// The scopes a user has consented to for a client.
+type OAuthGrant struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    string             `bson:"user_id"`
+	ClientID  string             `bson:"client_id"`
+	Scopes    []string           `bson:"scopes"`
+	CreatedAt primitive.DateTime `bson:"created_at"`
+	UpdatedAt primitive.DateTime `bson:"updated_at"`
+}
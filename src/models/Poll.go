@@ -0,0 +1,19 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type PollOption struct {
+	ID   string `bson:"id"`
+	Text string `bson:"text"`
+}
+
+type Poll struct {
+	Question       string              `bson:"question"`
+	Options        []PollOption        `bson:"options"`
+	MultipleChoice bool                `bson:"multiple_choice"`
+	Anonymous      bool                `bson:"anonymous"`
+	ClosesAt       *primitive.DateTime `bson:"closes_at,omitempty"`
+
+	// Option ids chosen by each user, keyed by user id
+	Votes map[string][]string `bson:"votes,omitempty"`
+}
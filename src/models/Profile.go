@@ -0,0 +1,49 @@
+package models
+
+// Who can see a profile field.
+const (
+	VisibilityPublic   = "public"
+	VisibilityContacts = "contacts"
+	VisibilityPrivate  = "private"
+)
+
+// The profile fields that can be hidden.
+const (
+	ProfileFieldBio      = "bio"
+	ProfileFieldPronouns = "pronouns"
+	ProfileFieldTimezone = "timezone"
+	ProfileFieldBanner   = "banner"
+	ProfileFieldLinks    = "links"
+)
+
+// Who can find the user in the user directory, set like the visibility of a field.
+const ProfileFieldDirectory = "directory"
+
+var ProfileFields = []string{ProfileFieldBio, ProfileFieldPronouns, ProfileFieldTimezone, ProfileFieldBanner, ProfileFieldLinks, ProfileFieldDirectory}
+
+type SocialLink struct {
+	Label string `bson:"label" json:"label"`
+	URL   string `bson:"url" json:"url"`
+}
+
+// Optional details shown on a user's profile. Visibility maps a field to who can see
+// it. Fields without an entry are public.
+type Profile struct {
+	Bio           string            `bson:"bio,omitempty" json:"bio,omitempty"`
+	Pronouns      string            `bson:"pronouns,omitempty" json:"pronouns,omitempty"`
+	Timezone      string            `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	BannerVersion string            `bson:"banner_version,omitempty" json:"banner_version,omitempty"`
+	Links         []SocialLink      `bson:"links,omitempty" json:"links,omitempty"`
+	Visibility    map[string]string `bson:"visibility,omitempty" json:"visibility,omitempty"`
+}
+
+// Returns who can see the field.
+func (p *Profile) FieldVisibility(field string) string {
+	if p == nil {
+		return VisibilityPublic
+	}
+	if visibility, ok := p.Visibility[field]; ok {
+		return visibility
+	}
+	return VisibilityPublic
+}
@@ -2,10 +2,22 @@ package models
 
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
+// One row is stored per refresh token, keyed by a hash of the token. Rotating a
+// token marks its row as rotated and adds a row for the new token to the same
+// family, so a family is the session of one device. Families issued to a
+// third-party app carry its ClientID and the scopes the user granted.
 type RefreshToken struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty"`
-	UserID       string             `bson:"user_id,omitempty"`
-	RefreshToken string             `bson:"refresh_token,omitempty"`
-	CreatedAt    primitive.DateTime `bson:"created_at,omitempty"`
-	ExpiresAt    primitive.DateTime `bson:"expires_at,omitempty"`
+	ID         primitive.ObjectID  `bson:"_id,omitempty"`
+	FamilyID   primitive.ObjectID  `bson:"family_id,omitempty"`
+	UserID     string              `bson:"user_id,omitempty"`
+	TokenHash  string              `bson:"token_hash,omitempty"`
+	ClientID   string              `bson:"client_id,omitempty"`
+	Scopes     []string            `bson:"scopes,omitempty"`
+	DeviceName string              `bson:"device_name,omitempty"`
+	IPAddress  string              `bson:"ip_address,omitempty"`
+	UserAgent  string              `bson:"user_agent,omitempty"`
+	CreatedAt  primitive.DateTime  `bson:"created_at,omitempty"`
+	LastUsedAt primitive.DateTime  `bson:"last_used_at,omitempty"`
+	RotatedAt  *primitive.DateTime `bson:"rotated_at,omitempty"`
+	ExpiresAt  primitive.DateTime  `bson:"expires_at,omitempty"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type ScheduledMessage struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty"`
+	SenderID    string              `bson:"sender_id"`
+	ChannelID   string              `bson:"channel_id"`
+	Content     string              `bson:"content"`
+	SendAt      primitive.DateTime  `bson:"send_at"`
+	Status      string              `bson:"status"`
+	Attempts    int                 `bson:"attempts"`
+	LockedUntil *primitive.DateTime `bson:"locked_until,omitempty"`
+	SentAt      *primitive.DateTime `bson:"sent_at,omitempty"`
+	CreatedAt   primitive.DateTime  `bson:"created_at"`
+	UpdatedAt   primitive.DateTime  `bson:"updated_at"`
+}
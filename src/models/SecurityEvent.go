@@ -0,0 +1,23 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+	SecurityEventRoleChanged       = "role_changed"
+	SecurityEventAccountDisabled   = "account_disabled"
+	SecurityEventAccountEnabled    = "account_enabled"
+	SecurityEventMessageDeleted    = "message_deleted"
+)
+
+type SecurityEvent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    string             `bson:"user_id"`
+	Type      string             `bson:"type"`
+	FamilyID  primitive.ObjectID `bson:"family_id,omitempty"`
+	IPAddress string             `bson:"ip_address,omitempty"`
+	UserAgent string             `bson:"user_agent,omitempty"`
+	ActorID   string             `bson:"actor_id,omitempty"`
+	Role      string             `bson:"role,omitempty"`
+	CreatedAt primitive.DateTime `bson:"created_at"`
+}
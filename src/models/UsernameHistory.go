@@ -0,0 +1,12 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// A username a user had before changing it, so links to the old name still resolve.
+type UsernameHistory struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserID      string             `bson:"user_id"`
+	Username    string             `bson:"username"`
+	UsernameKey string             `bson:"username_key"`
+	ChangedAt   primitive.DateTime `bson:"changed_at"`
+}
@@ -2,12 +2,97 @@ package models
 
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
+// Global roles. Each role can do everything the roles before it can.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Where a user's profile picture comes from. Users without a source show their
+// identity provider's photo, or an identicon when the provider has none.
+const (
+	AvatarSourceProvider  = "provider"
+	AvatarSourceCustom    = "custom"
+	AvatarSourceIdenticon = "identicon"
+)
+
+// Presence statuses. Users without a status are offline.
+const (
+	StatusOnline  = "online"
+	StatusOffline = "offline"
+)
+
+func ValidStatus(status string) bool {
+	return status == StatusOnline || status == StatusOffline
+}
+
+// Stands in for the sender of messages kept after their author deleted their account.
+const DeletedUserID = "deleted"
+
+var roleRanks = map[string]int{RoleUser: 0, RoleModerator: 1, RoleAdmin: 2}
+
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// Reports whether a user with the given role has at least the required role.
+// Users without a role are regular users.
+func HasRole(role string, required string) bool {
+	if role == "" {
+		role = RoleUser
+	}
+
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[required]
+}
+
 type User struct {
-	ID             primitive.ObjectID `bson:"_id,omitempty"`
-	FirebaseID     string             `bson:"firebase_id,omitempty"`
-	Email          string             `bson:"email,omitempty"`
-	Username       string             `bson:"username,omitempty"`
-	Status         string             `bson:"status,omitempty"`
-	CustomStatus   *string            `bson:"custom_status,omitempty"`
-	ProfilePicture *string            `bson:"profile_picture,omitempty"`
+	ID                primitive.ObjectID `bson:"_id,omitempty"`
+	FirebaseID        string             `bson:"firebase_id,omitempty"`
+	IdentityProvider  string             `bson:"identity_provider,omitempty"`
+	IdentitySubject   string             `bson:"identity_subject,omitempty"`
+	Email             string             `bson:"email,omitempty"`
+	EmailVerified     bool               `bson:"email_verified,omitempty"`
+	PasswordHash      string             `bson:"password_hash,omitempty"`
+	Username          string             `bson:"username,omitempty"`
+	UsernameKey       string             `bson:"username_key,omitempty"`
+	UsernameSearchKey string             `bson:"username_search_key,omitempty"`
+	Status            string             `bson:"status,omitempty"`
+	CustomStatus      *CustomStatus      `bson:"custom_status,omitempty"`
+	ProfilePicture    *string            `bson:"profile_picture,omitempty"`
+	Role              string             `bson:"role,omitempty"`
+	Disabled          bool               `bson:"disabled,omitempty"`
+
+	// ProfilePicture is the URL of the picture chosen by AvatarSource. ProviderPicture is the
+	// identity provider's photo and AvatarVersion names the files of the uploaded avatar.
+	ProviderPicture *string `bson:"provider_picture,omitempty"`
+	AvatarSource    string  `bson:"avatar_source,omitempty"`
+	AvatarVersion   string  `bson:"avatar_version,omitempty"`
+
+	Profile *Profile `bson:"profile,omitempty"`
+
+	// An optional name shown instead of the username. DisplayNameTerms holds its words
+	// for the user directory.
+	DisplayName      string   `bson:"display_name,omitempty"`
+	DisplayNameTerms []string `bson:"display_name_terms,omitempty"`
+
+	// Usernames can only be changed once per cooldown period.
+	UsernameChangedAt *primitive.DateTime `bson:"username_changed_at,omitempty"`
+
+	// Accounts are deleted once DeletionScheduledAt passes, unless the user cancels.
+	DeletionScheduledAt *primitive.DateTime `bson:"deletion_scheduled_at,omitempty"`
+	DeletionLockedUntil *primitive.DateTime `bson:"deletion_locked_until,omitempty"`
+
+	// Bots are owned by a human user and authenticate with API keys.
+	Bot     bool   `bson:"bot,omitempty"`
+	OwnerID string `bson:"owner_id,omitempty"`
+
+	// Two-factor authentication. RecoveryCodes holds hashes of the unused codes.
+	TOTPEnabled       bool     `bson:"totp_enabled,omitempty"`
+	TOTPSecret        string   `bson:"totp_secret,omitempty"`
+	TOTPPendingSecret string   `bson:"totp_pending_secret,omitempty"`
+	TOTPLastStep      int64    `bson:"totp_last_step,omitempty"`
+	RecoveryCodes     []string `bson:"recovery_codes,omitempty"`
 }
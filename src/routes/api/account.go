@@ -0,0 +1,533 @@
+package routes
+
+import (
+	"archive/zip"
+	"chat-app-back/src/avatar"
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ExportProfile struct {
+	ID                  string               `json:"id"`
+	Username            string               `json:"username"`
+	DisplayName         string               `json:"display_name,omitempty"`
+	Email               string               `json:"email"`
+	EmailVerified       bool                 `json:"email_verified"`
+	IdentityProvider    string               `json:"identity_provider"`
+	Status              string               `json:"status"`
+	CustomStatus        *models.CustomStatus `json:"custom_status"`
+	ProfilePicture      *string              `json:"profile_picture"`
+	Profile             *models.Profile      `json:"profile,omitempty"`
+	Role                string               `json:"role"`
+	TwoFactorEnabled    bool                 `json:"two_factor_enabled"`
+	DeletionScheduledAt *primitive.DateTime  `json:"deletion_scheduled_at,omitempty"`
+}
+
+type ExportFriendship struct {
+	UserID     string              `json:"user_id"`
+	Status     string              `json:"status"`
+	Outgoing   bool                `json:"outgoing"`
+	CreatedAt  primitive.DateTime  `json:"created_at"`
+	AcceptedAt *primitive.DateTime `json:"accepted_at,omitempty"`
+}
+
+type ExportBlock struct {
+	UserID    string             `json:"user_id"`
+	Kind      string             `json:"kind"`
+	CreatedAt primitive.DateTime `json:"created_at"`
+}
+
+type ExportMessage struct {
+	ID          string              `json:"id"`
+	ChannelID   string              `json:"channel_id"`
+	Type        string              `json:"type"`
+	Content     string              `json:"content"`
+	Poll        *PollResponse       `json:"poll,omitempty"`
+	Attachments []models.Attachment `json:"attachments,omitempty"`
+	CreatedAt   primitive.DateTime  `json:"created_at"`
+	ExpiresAt   *primitive.DateTime `json:"expires_at,omitempty"`
+}
+
+const (
+	accountDeletionGracePeriod = 30 * 24 * time.Hour
+	accountDeletionInterval    = time.Minute
+	accountDeletionLease       = 10 * time.Minute
+
+	// Shown as the author of messages whose author deleted their account
+	deletedUsername = "Deleted user"
+
+	// What happens to the messages of deleted accounts, set by MESSAGE_DELETION_POLICY
+	messagePolicyAnonymize = "anonymize"
+	messagePolicyDelete    = "delete"
+)
+
+// Returns the policy for the messages of deleted accounts. Messages are anonymized
+// by default so conversations keep their context.
+func messageDeletionPolicy() string {
+	if os.Getenv("MESSAGE_DELETION_POLICY") == messagePolicyDelete {
+		return messagePolicyDelete
+	}
+
+	return messagePolicyAnonymize
+}
+
+// Adds a JSON file to the archive.
+func writeJSONFile(archive *zip.Writer, name string, value any) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// Adds a stored file to the archive.
+func writeStoredFile(archive *zip.Writer, name string, key string) error {
+	reader, err := config.Storage().Open(key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, reader)
+	return err
+}
+
+// Streams a ZIP archive of the user's profile, messages, sessions and attachments.
+func HandleExportData(c *gin.Context) {
+	db := config.MongoClient()
+
+	user, err := fetchCurrentUser(c)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to export data"})
+		return
+	}
+	uid := user.ID.Hex()
+
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	profile := ExportProfile{
+		ID:                  uid,
+		Username:            user.Username,
+		DisplayName:         user.DisplayName,
+		Email:               user.Email,
+		EmailVerified:       user.EmailVerified,
+		IdentityProvider:    user.IdentityProvider,
+		Status:              user.Status,
+		CustomStatus:        user.CustomStatus,
+		ProfilePicture:      user.ProfilePicture,
+		Profile:             user.Profile,
+		Role:                role,
+		TwoFactorEnabled:    user.TOTPEnabled,
+		DeletionScheduledAt: user.DeletionScheduledAt,
+	}
+
+	cursor, err := db.Database("Chat-App").Collection("messages").Find(c, bson.M{"sender_id": uid}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to export data"})
+		return
+	}
+	var sent []models.Message
+	if err := cursor.All(c, &sent); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to export data"})
+		return
+	}
+
+	messages := []ExportMessage{}
+	for _, message := range sent {
+		messages = append(messages, ExportMessage{
+			ID:          message.ID.Hex(),
+			ChannelID:   message.ChannelID,
+			Type:        messageType(message),
+			Content:     message.Content,
+			Poll:        toPollResponse(message.Poll, uid),
+			Attachments: message.Attachments,
+			CreatedAt:   message.CreatedAt,
+			ExpiresAt:   message.ExpiresAt,
+		})
+	}
+
+	cursor, err = db.Database("Chat-App").Collection("refresh_tokens").Find(c, bson.M{"user_id": uid, "rotated_at": bson.M{"$exists": false}})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to export data"})
+		return
+	}
+	var tokens []models.RefreshToken
+	if err := cursor.All(c, &tokens); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to export data"})
+		return
+	}
+
+	sessions := []SessionResponse{}
+	for _, session := range tokens {
+		sessions = append(sessions, SessionResponse{
+			ID:         session.FamilyID.Hex(),
+			DeviceName: session.DeviceName,
+			ClientID:   session.ClientID,
+			IPAddress:  session.IPAddress,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+		})
+	}
+
+	cursor, err = db.Database("Chat-App").Collection("friendships").Find(c, bson.M{"user_ids": uid})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to export data"})
+		return
+	}
+	var friendships []models.Friendship
+	if err := cursor.All(c, &friendships); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to export data"})
+		return
+	}
+
+	friends := []ExportFriendship{}
+	for _, friendship := range friendships {
+		friends = append(friends, ExportFriendship{
+			UserID:     friendshipOther(friendship, uid),
+			Status:     friendship.Status,
+			Outgoing:   friendship.RequesterID == uid,
+			CreatedAt:  friendship.CreatedAt,
+			AcceptedAt: friendship.AcceptedAt,
+		})
+	}
+
+	cursor, err = db.Database("Chat-App").Collection("blocks").Find(c, bson.M{"user_id": uid})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to export data"})
+		return
+	}
+	var userBlocks []models.Block
+	if err := cursor.All(c, &userBlocks); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to export data"})
+		return
+	}
+
+	blocks := []ExportBlock{}
+	for _, block := range userBlocks {
+		blocks = append(blocks, ExportBlock{UserID: block.TargetID, Kind: block.Kind, CreatedAt: block.CreatedAt})
+	}
+
+	// The response has started once the archive is written, so errors can only be logged
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="chat-app-export-%s.zip"`, time.Now().Format("2006-01-02")))
+	c.Status(200)
+
+	archive := zip.NewWriter(c.Writer)
+	defer archive.Close()
+
+	files := []struct {
+		name  string
+		value any
+	}{
+		{"profile.json", profile},
+		{"messages.json", messages},
+		{"sessions.json", sessions},
+		{"friends.json", friends},
+		{"blocks.json", blocks},
+	}
+	for _, file := range files {
+		if err := writeJSONFile(archive, file.name, file.value); err != nil {
+			log.Println("error exporting data:", err)
+			return
+		}
+	}
+
+	for _, message := range sent {
+		for _, attachment := range message.Attachments {
+			name := path.Join("attachments", message.ID.Hex(), path.Base(attachment.Key))
+			if err := writeStoredFile(archive, name, attachment.Key); err != nil {
+				log.Println("error exporting attachment:", err)
+			}
+		}
+	}
+
+	if user.AvatarVersion != "" {
+		if err := writeStoredFile(archive, "avatar.png", avatar.Key(uid, user.AvatarVersion, avatar.DefaultSize)); err != nil {
+			log.Println("error exporting avatar:", err)
+		}
+	}
+	if user.Profile != nil && user.Profile.BannerVersion != "" {
+		if err := writeStoredFile(archive, "banner.png", avatar.BannerKey(uid, user.Profile.BannerVersion)); err != nil {
+			log.Println("error exporting banner:", err)
+		}
+	}
+}
+
+// Schedules the account for deletion after the grace period. The account keeps
+// working until then, and the deletion can be cancelled.
+func HandleRequestAccountDeletion(c *gin.Context) {
+	db := config.MongoClient()
+
+	objectID, err := primitive.ObjectIDFromHex(util.GetUid(c))
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid user ID"})
+		return
+	}
+
+	deletionAt := primitive.NewDateTimeFromTime(time.Now().Add(accountDeletionGracePeriod))
+	filter := bson.M{"_id": objectID, "deletion_scheduled_at": bson.M{"$exists": false}}
+	result, err := db.Database("Chat-App").Collection("users").UpdateOne(c, filter, bson.M{"$set": bson.M{"deletion_scheduled_at": deletionAt}})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to schedule account deletion"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(409, gin.H{"status": "error", "message": "Account deletion already scheduled"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Account deletion scheduled", "deletion_scheduled_at": deletionAt})
+}
+
+func HandleCancelAccountDeletion(c *gin.Context) {
+	db := config.MongoClient()
+
+	objectID, err := primitive.ObjectIDFromHex(util.GetUid(c))
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid user ID"})
+		return
+	}
+
+	// An account already being deleted cannot be saved
+	filter := bson.M{"_id": objectID, "deletion_scheduled_at": bson.M{"$exists": true}, "deletion_locked_until": bson.M{"$exists": false}}
+	result, err := db.Database("Chat-App").Collection("users").UpdateOne(c, filter, bson.M{"$unset": bson.M{"deletion_scheduled_at": ""}})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to cancel account deletion"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(404, gin.H{"status": "error", "message": "No account deletion scheduled"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Account deletion cancelled"})
+}
+
+// Claims the next account due for deletion. The claim is a lease, so an account held
+// by an instance that died is picked up again once the lease runs out.
+func claimAccountDeletion(ctx context.Context) (*models.User, error) {
+	db := config.MongoClient()
+
+	now := time.Now()
+	filter := bson.M{
+		"deletion_scheduled_at": bson.M{"$lte": primitive.NewDateTimeFromTime(now)},
+		"$or": bson.A{
+			bson.M{"deletion_locked_until": bson.M{"$exists": false}},
+			bson.M{"deletion_locked_until": bson.M{"$lte": primitive.NewDateTimeFromTime(now)}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"deletion_locked_until": primitive.NewDateTimeFromTime(now.Add(accountDeletionLease))}}
+
+	var user models.User
+	err := db.Database("Chat-App").Collection("users").FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetSort(bson.M{"deletion_scheduled_at": 1}).SetReturnDocument(options.After)).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Deletes or anonymizes the user's messages according to the policy and removes
+// the user from mentions, read receipts and poll votes.
+func deleteUserMessages(ctx context.Context, uid string) error {
+	messages := config.MongoClient().Database("Chat-App").Collection("messages")
+
+	if messageDeletionPolicy() == messagePolicyDelete {
+		cursor, err := messages.Find(ctx, bson.M{"sender_id": uid, "attachments.0": bson.M{"$exists": true}})
+		if err != nil {
+			return err
+		}
+		var withAttachments []models.Message
+		if err := cursor.All(ctx, &withAttachments); err != nil {
+			return err
+		}
+		for _, message := range withAttachments {
+			for _, attachment := range message.Attachments {
+				if err := config.Storage().Delete(attachment.Key); err != nil {
+					log.Println("error deleting attachment:", err)
+				}
+			}
+		}
+
+		if _, err := messages.DeleteMany(ctx, bson.M{"sender_id": uid}); err != nil {
+			return err
+		}
+	} else {
+		update := bson.M{"$set": bson.M{"sender_id": models.DeletedUserID}}
+		if _, err := messages.UpdateMany(ctx, bson.M{"sender_id": uid}, update); err != nil {
+			return err
+		}
+	}
+
+	voteKey := fmt.Sprintf("poll.votes.%s", uid)
+	updates := []struct {
+		filter bson.M
+		update bson.M
+	}{
+		{bson.M{"mentions": uid}, bson.M{"$pull": bson.M{"mentions": uid}}},
+		{bson.M{"read_by": uid}, bson.M{"$pull": bson.M{"read_by": uid}}},
+		{bson.M{voteKey: bson.M{"$exists": true}}, bson.M{"$unset": bson.M{voteKey: ""}}},
+	}
+	for _, u := range updates {
+		if _, err := messages.UpdateMany(ctx, u.filter, u.update); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deletes an account and everything that belongs to it, including the bots the user
+// owns. Every step can be repeated, so a deletion that fails is retried from the start.
+func deleteAccount(ctx context.Context, user models.User) error {
+	db := config.MongoClient().Database("Chat-App")
+	uid := user.ID.Hex()
+
+	cursor, err := db.Collection("users").Find(ctx, bson.M{"bot": true, "owner_id": uid})
+	if err != nil {
+		return err
+	}
+	var bots []models.User
+	if err := cursor.All(ctx, &bots); err != nil {
+		return err
+	}
+	for _, bot := range bots {
+		if err := deleteAccount(ctx, bot); err != nil {
+			return err
+		}
+	}
+
+	if err := deleteUserMessages(ctx, uid); err != nil {
+		return err
+	}
+
+	// Apps the user registered go away along with what was issued to them
+	cursor, err = db.Collection("oauth_clients").Find(ctx, bson.M{"owner_id": uid})
+	if err != nil {
+		return err
+	}
+	var clients []models.OAuthClient
+	if err := cursor.All(ctx, &clients); err != nil {
+		return err
+	}
+	for _, client := range clients {
+		for _, collection := range []string{"oauth_codes", "oauth_grants", "refresh_tokens"} {
+			if _, err := db.Collection(collection).DeleteMany(ctx, bson.M{"client_id": client.ClientID}); err != nil {
+				return err
+			}
+		}
+	}
+
+	deletions := []struct {
+		collection string
+		filter     bson.M
+	}{
+		{"refresh_tokens", bson.M{"user_id": uid}},
+		{"drafts", bson.M{"user_id": uid}},
+		{"scheduled_messages", bson.M{"sender_id": uid}},
+		{"email_tokens", bson.M{"user_id": uid}},
+		{"mfa_challenges", bson.M{"user_id": uid}},
+		{"security_events", bson.M{"user_id": uid}},
+		{"oauth_grants", bson.M{"user_id": uid}},
+		{"friendships", bson.M{"user_ids": uid}},
+		{"blocks", bson.M{"user_id": uid}},
+		{"blocks", bson.M{"target_id": uid}},
+		{"username_history", bson.M{"user_id": uid}},
+		{"oauth_codes", bson.M{"user_id": uid}},
+		{"oauth_clients", bson.M{"owner_id": uid}},
+		{"api_keys", bson.M{"bot_id": uid}},
+		{"api_keys", bson.M{"owner_id": uid}},
+	}
+	for _, deletion := range deletions {
+		if _, err := db.Collection(deletion.collection).DeleteMany(ctx, deletion.filter); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Collection("users").DeleteOne(ctx, bson.M{"_id": user.ID})
+	if err != nil {
+		return err
+	}
+	if user.AvatarVersion != "" {
+		deleteAvatarFiles(uid, user.AvatarVersion)
+	}
+	if user.Profile != nil && user.Profile.BannerVersion != "" {
+		if err := config.Storage().Delete(avatar.BannerKey(uid, user.Profile.BannerVersion)); err != nil {
+			log.Println("error deleting banner:", err)
+		}
+	}
+
+	broadcast("user_deleted", map[string]any{"id": uid})
+
+	return nil
+}
+
+// Deletes accounts whose grace period has passed until the context is cancelled.
+func StartAccountDeletionReaper(ctx context.Context) {
+	ticker := time.NewTicker(accountDeletionInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			user, err := claimAccountDeletion(ctx)
+			if err != nil {
+				log.Println("error claiming account deletion:", err)
+				break
+			}
+			if user == nil {
+				break
+			}
+
+			if err := deleteAccount(ctx, *user); err != nil {
+				log.Println("error deleting account:", err)
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func AccountRoutes(route *gin.RouterGroup) {
+	accountGroup := route.Group("/")
+	{
+		accountGroup.GET("export_data", middlewares.AuthenticateAccessToken(), HandleExportData)
+		accountGroup.POST("delete_account", middlewares.AuthenticateAccessToken(), HandleRequestAccountDeletion)
+		accountGroup.POST("cancel_delete_account", middlewares.AuthenticateAccessToken(), HandleCancelAccountDeletion)
+	}
+}
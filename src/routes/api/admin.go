@@ -0,0 +1,342 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ChangeRole struct {
+	UserID string `json:"user_id" validate:"required"`
+	Role   string `json:"role" validate:"required,oneof=user moderator admin"`
+}
+
+type AdminUserAction struct {
+	UserID string `json:"user_id" validate:"required"`
+}
+
+type ModerateMessage struct {
+	MessageID string `json:"message_id" validate:"required"`
+}
+
+type AdminUserResponse struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	Disabled  bool   `json:"disabled"`
+	Status    string `json:"status"`
+	Provider  string `json:"identity_provider"`
+	TwoFactor bool   `json:"two_factor"`
+}
+
+const maxAdminUsers = 100
+
+func toAdminUserResponse(user models.User) AdminUserResponse {
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+
+	return AdminUserResponse{
+		ID:        user.ID.Hex(),
+		Username:  user.Username,
+		Email:     user.Email,
+		Role:      role,
+		Disabled:  user.Disabled,
+		Status:    user.Status,
+		Provider:  user.IdentityProvider,
+		TwoFactor: user.TOTPEnabled,
+	}
+}
+
+// Logs the user out everywhere. Deleting the token families also invalidates
+// their access tokens, so the user's next tokens carry their new role.
+func revokeUserSessions(c *gin.Context, uid string) error {
+	db := config.MongoClient()
+
+	_, err := db.Database("Chat-App").Collection("refresh_tokens").DeleteMany(c, bson.M{"user_id": uid})
+	return err
+}
+
+// Lists users ordered by ID. Pass the last ID of a page as after to get the next one.
+func HandleAdminGetUsers(c *gin.Context) {
+	db := config.MongoClient()
+
+	filter := bson.M{}
+	if after := c.Query("after"); after != "" {
+		afterID, err := primitive.ObjectIDFromHex(after)
+		if err != nil {
+			c.JSON(400, gin.H{"status": "error", "message": "Invalid after ID"})
+			return
+		}
+		filter["_id"] = bson.M{"$gt": afterID}
+	}
+	if role := c.Query("role"); role != "" {
+		if !models.ValidRole(role) {
+			c.JSON(400, gin.H{"status": "error", "message": "Invalid role"})
+			return
+		}
+		filter["role"] = role
+		if role == models.RoleUser {
+			filter["role"] = bson.M{"$in": bson.A{nil, models.RoleUser}}
+		}
+	}
+	if c.Query("disabled") == "true" {
+		filter["disabled"] = true
+	}
+
+	limit := maxAdminUsers
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(400, gin.H{"status": "error", "message": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, maxAdminUsers)
+	}
+
+	findOptions := options.Find().SetSort(bson.M{"_id": 1}).SetLimit(int64(limit))
+	cursor, err := db.Database("Chat-App").Collection("users").Find(c, filter, findOptions)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch users"})
+		return
+	}
+	defer cursor.Close(c)
+
+	users := []AdminUserResponse{}
+	for cursor.Next(c) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch users"})
+			return
+		}
+
+		users = append(users, toAdminUserResponse(user))
+	}
+
+	if err := cursor.Err(); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch users"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "users": users})
+}
+
+func HandleAdminChangeRole(c *gin.Context) {
+	db := config.MongoClient()
+
+	var changeRole ChangeRole
+	err := c.BindJSON(&changeRole)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(changeRole)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	// Admins cannot demote themselves, so there is always at least one admin
+	uid := util.GetUid(c)
+	if changeRole.UserID == uid {
+		c.JSON(400, gin.H{"status": "error", "message": "You cannot change your own role"})
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(changeRole.UserID)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid user ID"})
+		return
+	}
+
+	var previous models.User
+	err = db.Database("Chat-App").Collection("users").FindOneAndUpdate(c, bson.M{"_id": objectID},
+		bson.M{"$set": bson.M{"role": changeRole.Role}}).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(404, gin.H{"status": "error", "message": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to change role"})
+		return
+	}
+
+	// A demoted user must not keep using tokens that carry the old role. Promoted
+	// users get the new role on their next token refresh.
+	if !models.HasRole(changeRole.Role, previous.Role) {
+		if err := revokeUserSessions(c, changeRole.UserID); err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to change role"})
+			return
+		}
+	}
+
+	device := util.DeviceFromRequest(c)
+	util.LogSecurityEvent(c, models.SecurityEvent{
+		UserID:    changeRole.UserID,
+		Type:      models.SecurityEventRoleChanged,
+		ActorID:   uid,
+		Role:      changeRole.Role,
+		IPAddress: device.IPAddress,
+		UserAgent: device.UserAgent,
+	})
+
+	c.JSON(200, gin.H{"status": "success", "message": "Role changed successfully"})
+}
+
+// Disables or re-enables an account. Disabled users are logged out and cannot log in.
+// Moderators can do this to regular users, admins to anyone but themselves.
+func setUserDisabled(c *gin.Context, disabled bool) {
+	db := config.MongoClient()
+
+	var action AdminUserAction
+	err := c.BindJSON(&action)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(action)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	uid := util.GetUid(c)
+	if action.UserID == uid {
+		c.JSON(400, gin.H{"status": "error", "message": "You cannot disable your own account"})
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(action.UserID)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid user ID"})
+		return
+	}
+
+	var target models.User
+	err = db.Database("Chat-App").Collection("users").FindOne(c, bson.M{"_id": objectID}).Decode(&target)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(404, gin.H{"status": "error", "message": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to update user"})
+		return
+	}
+
+	// Moderators can only disable regular users and bots
+	if !models.HasRole(util.GetClaims(c).Role, models.RoleAdmin) && models.HasRole(target.Role, models.RoleModerator) {
+		c.JSON(403, gin.H{"status": "error", "message": "Insufficient permissions"})
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"disabled": true, "status": models.StatusOffline}}
+	if !disabled {
+		update = bson.M{"$unset": bson.M{"disabled": ""}}
+	}
+	result, err := db.Database("Chat-App").Collection("users").UpdateOne(c, bson.M{"_id": objectID}, update)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to update user"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(404, gin.H{"status": "error", "message": "User not found"})
+		return
+	}
+
+	eventType := models.SecurityEventAccountEnabled
+	if disabled {
+		eventType = models.SecurityEventAccountDisabled
+		if err := revokeUserSessions(c, action.UserID); err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to update user"})
+			return
+		}
+		QueuePresenceChange(action.UserID, models.StatusOffline)
+	}
+
+	device := util.DeviceFromRequest(c)
+	util.LogSecurityEvent(c, models.SecurityEvent{
+		UserID:    action.UserID,
+		Type:      eventType,
+		ActorID:   uid,
+		IPAddress: device.IPAddress,
+		UserAgent: device.UserAgent,
+	})
+
+	c.JSON(200, gin.H{"status": "success", "message": "User updated successfully"})
+}
+
+func HandleAdminDisableUser(c *gin.Context) {
+	setUserDisabled(c, true)
+}
+
+func HandleAdminEnableUser(c *gin.Context) {
+	setUserDisabled(c, false)
+}
+
+// Deletes any message, recording who deleted it in the author's security events.
+func HandleModeratorDeleteMessage(c *gin.Context) {
+	db := config.MongoClient()
+
+	var moderateMessage ModerateMessage
+	err := c.BindJSON(&moderateMessage)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(moderateMessage)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(moderateMessage.MessageID)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid message ID"})
+		return
+	}
+
+	var message models.Message
+	err = db.Database("Chat-App").Collection("messages").FindOneAndDelete(c, bson.M{"_id": objectID}).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(404, gin.H{"status": "error", "message": "Message not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to delete message"})
+		return
+	}
+	announceMessageDeleted(message)
+
+	device := util.DeviceFromRequest(c)
+	util.LogSecurityEvent(c, models.SecurityEvent{
+		UserID:    message.SenderID,
+		Type:      models.SecurityEventMessageDeleted,
+		ActorID:   util.GetUid(c),
+		IPAddress: device.IPAddress,
+		UserAgent: device.UserAgent,
+	})
+
+	c.JSON(200, gin.H{"status": "success", "message": "Message deleted successfully"})
+}
+
+func AdminRoutes(route *gin.RouterGroup) {
+	adminGroup := route.Group("/admin")
+	adminGroup.Use(middlewares.AuthenticateAccessToken())
+	{
+		adminGroup.GET("users", middlewares.RequireRole(models.RoleAdmin), HandleAdminGetUsers)
+		adminGroup.POST("change_role", middlewares.RequireRole(models.RoleAdmin), HandleAdminChangeRole)
+		adminGroup.POST("disable_user", middlewares.RequireRole(models.RoleModerator), HandleAdminDisableUser)
+		adminGroup.POST("enable_user", middlewares.RequireRole(models.RoleModerator), HandleAdminEnableUser)
+		adminGroup.POST("delete_message", middlewares.RequireRole(models.RoleModerator), HandleModeratorDeleteMessage)
+	}
+}
@@ -0,0 +1,429 @@
+package routes
+
+import (
+	"bytes"
+	"chat-app-back/src/avatar"
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ChangeAvatarSource struct {
+	Source string `json:"source" validate:"required,oneof=provider custom identicon"`
+}
+
+var imageVersionPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// Names a new upload of an image. Uploads never overwrite each other, so their URLs
+// can be cached indefinitely.
+func newImageVersion() (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// URLs of every size of the user's uploaded avatar, keyed by size.
+func avatarURLs(user models.User) map[string]string {
+	if user.AvatarVersion == "" {
+		return nil
+	}
+
+	urls := make(map[string]string, len(avatar.Sizes))
+	for _, size := range avatar.Sizes {
+		urls[strconv.Itoa(size)] = avatar.URL(user.ID.Hex(), user.AvatarVersion, size)
+	}
+	return urls
+}
+
+// Deletes the stored files of an uploaded avatar.
+func deleteAvatarFiles(userID string, version string) {
+	for _, size := range avatar.Sizes {
+		if err := config.Storage().Delete(avatar.Key(userID, version, size)); err != nil {
+			log.Println("error deleting avatar:", err)
+		}
+	}
+}
+
+// Saves the user's new avatar settings and points profile_picture at the picture they select.
+func updateAvatar(c *gin.Context, user *models.User) error {
+	db := config.MongoClient()
+
+	profilePicture := avatar.ProfilePictureURL(*user)
+	user.ProfilePicture = &profilePicture
+
+	set := bson.M{"profile_picture": profilePicture, "avatar_source": user.AvatarSource}
+	update := bson.M{"$set": set}
+	if user.AvatarVersion != "" {
+		set["avatar_version"] = user.AvatarVersion
+	} else {
+		update["$unset"] = bson.M{"avatar_version": ""}
+	}
+
+	_, err := db.Database("Chat-App").Collection("users").UpdateOne(c, bson.M{"_id": user.ID}, update)
+	return err
+}
+
+// Accepts an image in the avatar form field, crops it to a square and stores it in
+// every avatar size. The uploaded avatar becomes the profile picture.
+func HandleUploadAvatar(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, avatar.MaxUploadBytes+64<<10)
+
+	header, err := c.FormFile("avatar")
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Missing avatar image"})
+		return
+	}
+	if header.Size > avatar.MaxUploadBytes {
+		c.JSON(413, gin.H{"status": "error", "message": avatar.ErrImageTooLarge.Error()})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Missing avatar image"})
+		return
+	}
+	defer file.Close()
+
+	images, err := avatar.Process(file)
+	if err == avatar.ErrImageTooLarge {
+		c.JSON(413, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err == avatar.ErrUnsupportedImage {
+		c.JSON(400, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to upload avatar"})
+		return
+	}
+
+	user, err := fetchCurrentUser(c)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to upload avatar"})
+		return
+	}
+	uid := user.ID.Hex()
+
+	version, err := newImageVersion()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to upload avatar"})
+		return
+	}
+
+	for size, data := range images {
+		if err := config.Storage().Save(avatar.Key(uid, version, size), bytes.NewReader(data)); err != nil {
+			deleteAvatarFiles(uid, version)
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to upload avatar"})
+			return
+		}
+	}
+
+	previousVersion := user.AvatarVersion
+	user.AvatarVersion = version
+	user.AvatarSource = models.AvatarSourceCustom
+	if err := updateAvatar(c, user); err != nil {
+		deleteAvatarFiles(uid, version)
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to upload avatar"})
+		return
+	}
+
+	if previousVersion != "" {
+		deleteAvatarFiles(uid, previousVersion)
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Avatar uploaded successfully", "profile_picture": user.ProfilePicture, "avatar": avatarURLs(*user)})
+}
+
+// Chooses between the uploaded avatar, the identity provider's photo and an identicon.
+func HandleChangeAvatarSource(c *gin.Context) {
+	var changeAvatarSource ChangeAvatarSource
+
+	// Validate json structure
+	err := c.BindJSON(&changeAvatarSource)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(changeAvatarSource)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	user, err := fetchCurrentUser(c)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to change avatar"})
+		return
+	}
+
+	switch changeAvatarSource.Source {
+	case models.AvatarSourceCustom:
+		if user.AvatarVersion == "" {
+			c.JSON(400, gin.H{"status": "error", "message": "No avatar has been uploaded"})
+			return
+		}
+	case models.AvatarSourceProvider:
+		if user.ProviderPicture == nil || *user.ProviderPicture == "" {
+			c.JSON(400, gin.H{"status": "error", "message": "Your sign-in provider has no picture"})
+			return
+		}
+	}
+
+	user.AvatarSource = changeAvatarSource.Source
+	if err := updateAvatar(c, user); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to change avatar"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Avatar changed successfully", "profile_picture": user.ProfilePicture})
+}
+
+// Removes the uploaded avatar. The profile picture falls back to the identity
+// provider's photo or an identicon.
+func HandleDeleteAvatar(c *gin.Context) {
+	user, err := fetchCurrentUser(c)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to delete avatar"})
+		return
+	}
+	if user.AvatarVersion == "" {
+		c.JSON(404, gin.H{"status": "error", "message": "No avatar has been uploaded"})
+		return
+	}
+
+	version := user.AvatarVersion
+	user.AvatarVersion = ""
+	if user.AvatarSource == models.AvatarSourceCustom {
+		user.AvatarSource = ""
+	}
+	if err := updateAvatar(c, user); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to delete avatar"})
+		return
+	}
+	deleteAvatarFiles(user.ID.Hex(), version)
+
+	c.JSON(200, gin.H{"status": "success", "message": "Avatar deleted successfully", "profile_picture": user.ProfilePicture})
+}
+
+// Serves one size of an uploaded avatar. The files for a version never change, so
+// they can be cached indefinitely.
+func HandleGetAvatar(c *gin.Context) {
+	userID := c.Param("user_id")
+	version := c.Param("version")
+	size, err := strconv.Atoi(c.Param("size"))
+	if !primitive.IsValidObjectID(userID) || !imageVersionPattern.MatchString(version) || err != nil || !avatar.ValidSize(size) {
+		c.JSON(404, gin.H{"status": "error", "message": "Avatar not found"})
+		return
+	}
+
+	reader, err := config.Storage().Open(avatar.Key(userID, version, size))
+	if err != nil {
+		c.JSON(404, gin.H{"status": "error", "message": "Avatar not found"})
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(200, -1, "image/png", reader, map[string]string{"Cache-Control": "public, max-age=31536000, immutable"})
+}
+
+// Stores an uploaded image, cropped to 3:1, as the user's profile banner.
+func HandleUploadBanner(c *gin.Context) {
+	db := config.MongoClient()
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, avatar.MaxUploadBytes+64<<10)
+
+	header, err := c.FormFile("banner")
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Missing banner image"})
+		return
+	}
+	if header.Size > avatar.MaxUploadBytes {
+		c.JSON(413, gin.H{"status": "error", "message": avatar.ErrImageTooLarge.Error()})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Missing banner image"})
+		return
+	}
+	defer file.Close()
+
+	data, err := avatar.ProcessBanner(file)
+	if err == avatar.ErrImageTooLarge {
+		c.JSON(413, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err == avatar.ErrUnsupportedImage {
+		c.JSON(400, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to upload banner"})
+		return
+	}
+
+	user, err := fetchCurrentUser(c)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to upload banner"})
+		return
+	}
+	uid := user.ID.Hex()
+
+	version, err := newImageVersion()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to upload banner"})
+		return
+	}
+	if err := config.Storage().Save(avatar.BannerKey(uid, version), bytes.NewReader(data)); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to upload banner"})
+		return
+	}
+
+	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"profile.banner_version": version}})
+	if err != nil {
+		config.Storage().Delete(avatar.BannerKey(uid, version))
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to upload banner"})
+		return
+	}
+
+	if user.Profile != nil && user.Profile.BannerVersion != "" {
+		if err := config.Storage().Delete(avatar.BannerKey(uid, user.Profile.BannerVersion)); err != nil {
+			log.Println("error deleting banner:", err)
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Banner uploaded successfully", "banner": avatar.BannerURL(uid, version)})
+}
+
+func HandleDeleteBanner(c *gin.Context) {
+	db := config.MongoClient()
+
+	user, err := fetchCurrentUser(c)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to delete banner"})
+		return
+	}
+	if user.Profile == nil || user.Profile.BannerVersion == "" {
+		c.JSON(404, gin.H{"status": "error", "message": "No banner has been uploaded"})
+		return
+	}
+
+	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, bson.M{"_id": user.ID}, bson.M{"$unset": bson.M{"profile.banner_version": ""}})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to delete banner"})
+		return
+	}
+	if err := config.Storage().Delete(avatar.BannerKey(user.ID.Hex(), user.Profile.BannerVersion)); err != nil {
+		log.Println("error deleting banner:", err)
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Banner deleted successfully"})
+}
+
+// Moves the user's banner to a new version, so URLs handed out for the old one stop
+// working. Used when fewer users are allowed to see the banner.
+func rotateBanner(ctx context.Context, user *models.User) error {
+	db := config.MongoClient()
+
+	uid := user.ID.Hex()
+	version := user.Profile.BannerVersion
+	newVersion, err := newImageVersion()
+	if err != nil {
+		return err
+	}
+
+	reader, err := config.Storage().Open(avatar.BannerKey(uid, version))
+	if err != nil {
+		return err
+	}
+	err = config.Storage().Save(avatar.BannerKey(uid, newVersion), reader)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	// A banner uploaded meanwhile replaces this one, so the copy is dropped
+	result, err := db.Database("Chat-App").Collection("users").UpdateOne(ctx,
+		bson.M{"_id": user.ID, "profile.banner_version": version},
+		bson.M{"$set": bson.M{"profile.banner_version": newVersion}})
+	if err != nil || result.ModifiedCount == 0 {
+		config.Storage().Delete(avatar.BannerKey(uid, newVersion))
+		return err
+	}
+
+	if err := config.Storage().Delete(avatar.BannerKey(uid, version)); err != nil {
+		log.Println("error deleting banner:", err)
+	}
+	user.Profile.BannerVersion = newVersion
+	return nil
+}
+
+// Serves a profile banner. Banner URLs are only handed out to users allowed to see
+// the banner, and the random version keeps them from being guessed.
+// Browsers may cache them, but shared caches must not, as a banner may not be public.
+func HandleGetBanner(c *gin.Context) {
+	userID := c.Param("user_id")
+	version := c.Param("version")
+	if !primitive.IsValidObjectID(userID) || !imageVersionPattern.MatchString(version) {
+		c.JSON(404, gin.H{"status": "error", "message": "Banner not found"})
+		return
+	}
+
+	reader, err := config.Storage().Open(avatar.BannerKey(userID, version))
+	if err != nil {
+		c.JSON(404, gin.H{"status": "error", "message": "Banner not found"})
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(200, -1, "image/png", reader, map[string]string{"Cache-Control": "private, max-age=31536000, immutable"})
+}
+
+// Serves the generated identicon for a user.
+func HandleGetIdenticon(c *gin.Context) {
+	userID := c.Param("user_id")
+	size, err := strconv.Atoi(c.Param("size"))
+	if !primitive.IsValidObjectID(userID) || err != nil || !avatar.ValidSize(size) {
+		c.JSON(404, gin.H{"status": "error", "message": "Identicon not found"})
+		return
+	}
+
+	data, err := avatar.IdenticonPNG(userID, size)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to generate identicon"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Data(200, "image/png", data)
+}
+
+func AvatarRoutes(route *gin.RouterGroup) {
+	avatarGroup := route.Group("/")
+	{
+		avatarGroup.POST("upload_avatar", middlewares.AuthenticateAccessToken(), HandleUploadAvatar)
+		avatarGroup.POST("avatar_source", middlewares.AuthenticateAccessToken(), HandleChangeAvatarSource)
+		avatarGroup.POST("delete_avatar", middlewares.AuthenticateAccessToken(), HandleDeleteAvatar)
+		avatarGroup.GET("avatars/:user_id/:version/:size", HandleGetAvatar)
+		avatarGroup.GET("identicons/:user_id/:size", HandleGetIdenticon)
+		avatarGroup.POST("upload_banner", middlewares.AuthenticateAccessToken(), HandleUploadBanner)
+		avatarGroup.POST("delete_banner", middlewares.AuthenticateAccessToken(), HandleDeleteBanner)
+		avatarGroup.GET("banners/:user_id/:version", HandleGetBanner)
+	}
+}
@@ -0,0 +1,241 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var blockKindPastTense = map[string]string{models.BlockKindBlock: "blocked", models.BlockKindMute: "muted"}
+
+type BlockedUserResponse struct {
+	UserProfileResponse
+	Since primitive.DateTime `json:"since"`
+}
+
+// Returns the IDs of the users the user has blocked.
+func blockedIDs(ctx context.Context, uid string) ([]string, error) {
+	return blockTargets(ctx, bson.M{"user_id": uid, "kind": models.BlockKindBlock}, "target_id")
+}
+
+// Returns the IDs of the users who have restricted the target with one of the kinds.
+func blockerIDs(ctx context.Context, targetID string, kinds ...string) ([]string, error) {
+	return blockTargets(ctx, bson.M{"target_id": targetID, "kind": bson.M{"$in": kinds}}, "user_id")
+}
+
+func blockTargets(ctx context.Context, filter bson.M, field string) ([]string, error) {
+	db := config.MongoClient()
+
+	values, err := db.Database("Chat-App").Collection("blocks").Distinct(ctx, field, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(values))
+	for _, value := range values {
+		if id, ok := value.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Reports whether either user has blocked the other.
+func blockedEitherWay(ctx context.Context, uid string, otherID string) (bool, error) {
+	db := config.MongoClient()
+
+	count, err := db.Database("Chat-App").Collection("blocks").CountDocuments(ctx, bson.M{
+		"kind": models.BlockKindBlock,
+		"$or": bson.A{
+			bson.M{"user_id": uid, "target_id": otherID},
+			bson.M{"user_id": otherID, "target_id": uid},
+		},
+	})
+	return count > 0, err
+}
+
+// Matches messages not sent by users the viewer has blocked. Uses $and so it can be
+// combined with a filter on the sender.
+func notBlockedFilter(ctx context.Context, uid string) (bson.M, error) {
+	blocked, err := blockedIDs(ctx, uid)
+	if err != nil || len(blocked) == 0 {
+		return bson.M{}, err
+	}
+
+	return bson.M{"$and": bson.A{bson.M{"sender_id": bson.M{"$nin": blocked}}}}, nil
+}
+
+// Ends the friendship or pending friend request between the users, if there is one.
+func endFriendshipForBlock(ctx context.Context, uid string, targetID string) error {
+	db := config.MongoClient()
+
+	var friendship models.Friendship
+	err := db.Database("Chat-App").Collection("friendships").FindOneAndDelete(ctx,
+		bson.M{"pair_key": friendshipPairKey(uid, targetID)}).Decode(&friendship)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case friendship.Status == models.FriendshipAccepted:
+		notifyFriendship("friend_removed", friendship)
+	case friendship.RequesterID == uid:
+		notifyFriendship("friend_request_cancelled", friendship)
+	default:
+		notifyFriendship("friend_request_declined", friendship)
+	}
+	return nil
+}
+
+// Blocks or mutes the user in the request body.
+func restrictUser(c *gin.Context, kind string) {
+	db := config.MongoClient()
+
+	targetID, ok := bindUserAction(c)
+	if !ok {
+		return
+	}
+	uid := util.GetUid(c)
+	if targetID == uid {
+		c.JSON(400, gin.H{"status": "error", "message": "You can't " + kind + " yourself"})
+		return
+	}
+
+	objectID, _ := primitive.ObjectIDFromHex(targetID)
+	count, err := db.Database("Chat-App").Collection("users").CountDocuments(c, bson.M{"_id": objectID})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to " + kind + " user"})
+		return
+	}
+	if count == 0 {
+		c.JSON(404, gin.H{"status": "error", "message": "User not found"})
+		return
+	}
+
+	_, err = db.Database("Chat-App").Collection("blocks").InsertOne(c, models.Block{
+		ID:        primitive.NewObjectID(),
+		UserID:    uid,
+		TargetID:  targetID,
+		Kind:      kind,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	})
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to " + kind + " user"})
+		return
+	}
+
+	if kind == models.BlockKindBlock {
+		if err := endFriendshipForBlock(c, uid, targetID); err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to block user"})
+			return
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "User " + blockKindPastTense[kind]})
+}
+
+// Lifts a block or mute on the user in the request body.
+func unrestrictUser(c *gin.Context, kind string) {
+	db := config.MongoClient()
+
+	targetID, ok := bindUserAction(c)
+	if !ok {
+		return
+	}
+
+	result, err := db.Database("Chat-App").Collection("blocks").DeleteOne(c,
+		bson.M{"user_id": util.GetUid(c), "target_id": targetID, "kind": kind})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to un" + kind + " user"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(404, gin.H{"status": "error", "message": "User is not " + blockKindPastTense[kind]})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "User un" + blockKindPastTense[kind]})
+}
+
+func HandleBlockUser(c *gin.Context) {
+	restrictUser(c, models.BlockKindBlock)
+}
+
+func HandleUnblockUser(c *gin.Context) {
+	unrestrictUser(c, models.BlockKindBlock)
+}
+
+func HandleMuteUser(c *gin.Context) {
+	restrictUser(c, models.BlockKindMute)
+}
+
+func HandleUnmuteUser(c *gin.Context) {
+	unrestrictUser(c, models.BlockKindMute)
+}
+
+// Lists the users the user has blocked and muted.
+func HandleGetBlockedUsers(c *gin.Context) {
+	db := config.MongoClient()
+
+	uid := util.GetUid(c)
+	cursor, err := db.Database("Chat-App").Collection("blocks").Find(c, bson.M{"user_id": uid})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch blocked users"})
+		return
+	}
+	var blocks []models.Block
+	if err := cursor.All(c, &blocks); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch blocked users"})
+		return
+	}
+
+	ids := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		ids = append(ids, block.TargetID)
+	}
+	users, err := fetchUsersByID(c, ids)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch blocked users"})
+		return
+	}
+
+	blocked := []BlockedUserResponse{}
+	muted := []BlockedUserResponse{}
+	for _, block := range blocks {
+		user, ok := users[block.TargetID]
+		if !ok {
+			continue
+		}
+
+		entry := BlockedUserResponse{UserProfileResponse: profileResponse(user), Since: block.CreatedAt}
+		if block.Kind == models.BlockKindBlock {
+			blocked = append(blocked, entry)
+		} else {
+			muted = append(muted, entry)
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "blocked": blocked, "muted": muted})
+}
+
+func BlockRoutes(route *gin.RouterGroup) {
+	blockGroup := route.Group("/")
+	{
+		blockGroup.POST("block_user", middlewares.AuthenticateAccessToken(), HandleBlockUser)
+		blockGroup.POST("unblock_user", middlewares.AuthenticateAccessToken(), HandleUnblockUser)
+		blockGroup.POST("mute_user", middlewares.AuthenticateAccessToken(), HandleMuteUser)
+		blockGroup.POST("unmute_user", middlewares.AuthenticateAccessToken(), HandleUnmuteUser)
+		blockGroup.GET("blocked_users", middlewares.AuthenticateAccessToken(), HandleGetBlockedUsers)
+	}
+}
@@ -0,0 +1,361 @@
+package routes
+
+import (
+	"chat-app-back/src/avatar"
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CreateBot struct {
+	Username string `json:"username" validate:"required"`
+}
+
+type BotAction struct {
+	BotID string `json:"bot_id" validate:"required"`
+}
+
+type CreateAPIKey struct {
+	BotID  string   `json:"bot_id" validate:"required"`
+	Name   string   `json:"name" validate:"required,max=64"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=messages:read messages:write"`
+}
+
+type RevokeAPIKey struct {
+	KeyID string `json:"key_id" validate:"required"`
+}
+
+type BotResponse struct {
+	ID        string             `json:"id"`
+	Username  string             `json:"username"`
+	Disabled  bool               `json:"disabled"`
+	CreatedAt primitive.DateTime `json:"created_at"`
+}
+
+type APIKeyResponse struct {
+	ID         string              `json:"id"`
+	Name       string              `json:"name"`
+	Prefix     string              `json:"prefix"`
+	Scopes     []string            `json:"scopes"`
+	CreatedAt  primitive.DateTime  `json:"created_at"`
+	LastUsedAt *primitive.DateTime `json:"last_used_at"`
+}
+
+const (
+	maxBotsPerUser   = 10
+	maxAPIKeysPerBot = 10
+
+	// How much of a key is kept in the clear to identify it
+	apiKeyDisplayLength = 12
+)
+
+// Fetches a bot owned by the current user.
+func fetchOwnedBot(c *gin.Context, botID string) (*models.User, error) {
+	db := config.MongoClient()
+
+	objectID, err := primitive.ObjectIDFromHex(botID)
+	if err != nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	var bot models.User
+	filter := bson.M{"_id": objectID, "bot": true, "owner_id": util.GetUid(c)}
+	err = db.Database("Chat-App").Collection("users").FindOne(c, filter).Decode(&bot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bot, nil
+}
+
+func HandleCreateBot(c *gin.Context) {
+	db := config.MongoClient()
+
+	var createBot CreateBot
+	err := c.BindJSON(&createBot)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(createBot)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	uid := util.GetUid(c)
+
+	// Bots cannot own bots
+	owner, err := fetchCurrentUser(c)
+	if err != nil || owner.Bot {
+		c.JSON(403, gin.H{"status": "error", "message": "Not allowed to create bots"})
+		return
+	}
+
+	count, err := db.Database("Chat-App").Collection("users").CountDocuments(c, bson.M{"bot": true, "owner_id": uid})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to create bot"})
+		return
+	}
+	if count >= maxBotsPerUser {
+		c.JSON(400, gin.H{"status": "error", "message": "Bot limit reached"})
+		return
+	}
+
+	username, usernameKey, err := util.PrepareUsername(c, createBot.Username, "")
+	if err != nil {
+		util.RespondWithUsernameError(c, err, "Failed to create bot")
+		return
+	}
+
+	bot := models.User{
+		ID:                primitive.NewObjectID(),
+		Username:          username,
+		UsernameKey:       usernameKey,
+		UsernameSearchKey: util.UsernameSearchKey(username),
+		Status:            models.StatusOnline,
+		Bot:               true,
+		OwnerID:           uid,
+	}
+	profilePicture := avatar.ProfilePictureURL(bot)
+	bot.ProfilePicture = &profilePicture
+	_, err = db.Database("Chat-App").Collection("users").InsertOne(c, bot)
+	if mongo.IsDuplicateKeyError(err) {
+		util.RespondWithUsernameError(c, util.ErrUsernameTaken, "Failed to create bot")
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to create bot"})
+		return
+	}
+	QueuePresenceChange(bot.ID.Hex(), models.StatusOnline)
+
+	c.JSON(200, gin.H{"status": "success", "message": "Bot created successfully", "id": bot.ID.Hex()})
+}
+
+func HandleGetBots(c *gin.Context) {
+	db := config.MongoClient()
+
+	filter := bson.M{"bot": true, "owner_id": util.GetUid(c)}
+	cursor, err := db.Database("Chat-App").Collection("users").Find(c, filter, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch bots"})
+		return
+	}
+	defer cursor.Close(c)
+
+	bots := []BotResponse{}
+	for cursor.Next(c) {
+		var bot models.User
+		if err := cursor.Decode(&bot); err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch bots"})
+			return
+		}
+
+		bots = append(bots, BotResponse{
+			ID:        bot.ID.Hex(),
+			Username:  bot.Username,
+			Disabled:  bot.Disabled,
+			CreatedAt: primitive.NewDateTimeFromTime(bot.ID.Timestamp()),
+		})
+	}
+
+	if err := cursor.Err(); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch bots"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "bots": bots})
+}
+
+// Disables the bot and revokes its keys. The bot user is kept so its messages keep their author.
+func HandleDeleteBot(c *gin.Context) {
+	db := config.MongoClient()
+
+	var botAction BotAction
+	err := c.BindJSON(&botAction)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(botAction)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	bot, err := fetchOwnedBot(c, botAction.BotID)
+	if err != nil {
+		c.JSON(404, gin.H{"status": "error", "message": "Bot not found"})
+		return
+	}
+
+	_, err = db.Database("Chat-App").Collection("api_keys").DeleteMany(c, bson.M{"bot_id": bot.ID.Hex()})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to delete bot"})
+		return
+	}
+
+	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, bson.M{"_id": bot.ID}, bson.M{"$set": bson.M{"disabled": true, "status": models.StatusOffline}})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to delete bot"})
+		return
+	}
+	QueuePresenceChange(bot.ID.Hex(), models.StatusOffline)
+
+	c.JSON(200, gin.H{"status": "success", "message": "Bot deleted successfully"})
+}
+
+// Creates an API key for a bot. The key is only returned once.
+func HandleCreateAPIKey(c *gin.Context) {
+	db := config.MongoClient()
+
+	var createAPIKey CreateAPIKey
+	err := c.BindJSON(&createAPIKey)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(createAPIKey)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	bot, err := fetchOwnedBot(c, createAPIKey.BotID)
+	if err != nil || bot.Disabled {
+		c.JSON(404, gin.H{"status": "error", "message": "Bot not found"})
+		return
+	}
+
+	count, err := db.Database("Chat-App").Collection("api_keys").CountDocuments(c, bson.M{"bot_id": bot.ID.Hex()})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to create API key"})
+		return
+	}
+	if count >= maxAPIKeysPerBot {
+		c.JSON(400, gin.H{"status": "error", "message": "API key limit reached"})
+		return
+	}
+
+	key, err := util.GenerateAPIKey()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to create API key"})
+		return
+	}
+
+	apiKey := models.APIKey{
+		ID:        primitive.NewObjectID(),
+		BotID:     bot.ID.Hex(),
+		OwnerID:   util.GetUid(c),
+		Name:      createAPIKey.Name,
+		Prefix:    key[:apiKeyDisplayLength],
+		KeyHash:   util.HashToken(key),
+		Scopes:    createAPIKey.Scopes,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	}
+	_, err = db.Database("Chat-App").Collection("api_keys").InsertOne(c, apiKey)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "API key created successfully", "id": apiKey.ID.Hex(), "api_key": key})
+}
+
+func HandleGetAPIKeys(c *gin.Context) {
+	db := config.MongoClient()
+
+	bot, err := fetchOwnedBot(c, c.Param("bot_id"))
+	if err != nil {
+		c.JSON(404, gin.H{"status": "error", "message": "Bot not found"})
+		return
+	}
+
+	cursor, err := db.Database("Chat-App").Collection("api_keys").Find(c, bson.M{"bot_id": bot.ID.Hex()}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch API keys"})
+		return
+	}
+	defer cursor.Close(c)
+
+	apiKeys := []APIKeyResponse{}
+	for cursor.Next(c) {
+		var apiKey models.APIKey
+		if err := cursor.Decode(&apiKey); err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch API keys"})
+			return
+		}
+
+		apiKeys = append(apiKeys, APIKeyResponse{
+			ID:         apiKey.ID.Hex(),
+			Name:       apiKey.Name,
+			Prefix:     apiKey.Prefix,
+			Scopes:     apiKey.Scopes,
+			CreatedAt:  apiKey.CreatedAt,
+			LastUsedAt: apiKey.LastUsedAt,
+		})
+	}
+
+	if err := cursor.Err(); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch API keys"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "api_keys": apiKeys})
+}
+
+func HandleRevokeAPIKey(c *gin.Context) {
+	db := config.MongoClient()
+
+	var revokeAPIKey RevokeAPIKey
+	err := c.BindJSON(&revokeAPIKey)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(revokeAPIKey)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	keyID, err := primitive.ObjectIDFromHex(revokeAPIKey.KeyID)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid key ID"})
+		return
+	}
+
+	// Keys are checked on every request, so deleting one revokes it right away
+	result, err := db.Database("Chat-App").Collection("api_keys").DeleteOne(c, bson.M{"_id": keyID, "owner_id": util.GetUid(c)})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to revoke API key"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(404, gin.H{"status": "error", "message": "API key not found"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "API key revoked successfully"})
+}
+
+func BotRoutes(route *gin.RouterGroup) {
+	botGroup := route.Group("/")
+	{
+		botGroup.POST("create_bot", middlewares.AuthenticateAccessToken(), HandleCreateBot)
+		botGroup.GET("bots", middlewares.AuthenticateAccessToken(), HandleGetBots)
+		botGroup.POST("delete_bot", middlewares.AuthenticateAccessToken(), HandleDeleteBot)
+		botGroup.POST("create_api_key", middlewares.AuthenticateAccessToken(), HandleCreateAPIKey)
+		botGroup.GET("api_keys/:bot_id", middlewares.AuthenticateAccessToken(), HandleGetAPIKeys)
+		botGroup.POST("revoke_api_key", middlewares.AuthenticateAccessToken(), HandleRevokeAPIKey)
+	}
+}
@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/models"
+	"context"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const statusReaperInterval = time.Minute
+
+// The longest standard ZWJ sequences, such as a family of four, have this many parts.
+const maxEmojiParts = 4
+
+// Reports whether the text is a single emoji: one emoji, or a few joined into one by
+// zero width joiners.
+func validStatusEmoji(emoji string) bool {
+	parts := strings.Split(emoji, "\u200d")
+	if len(parts) > maxEmojiParts {
+		return false
+	}
+
+	for _, part := range parts {
+		if !validEmojiPart([]rune(part)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reports whether the runes are one emoji: a flag, a keycap, or a symbol followed by
+// an optional variation selector, skin tone or subdivision flag tags.
+func validEmojiPart(runes []rune) bool {
+	if len(runes) == 0 {
+		return false
+	}
+
+	first := runes[0]
+	switch {
+	case isRegionalIndicator(first):
+		return len(runes) == 2 && isRegionalIndicator(runes[1])
+	case first == '#' || first == '*' || (first >= '0' && first <= '9'):
+		rest := runes[1:]
+		if len(rest) > 0 && rest[0] == '\ufe0f' {
+			rest = rest[1:]
+		}
+		return len(rest) == 1 && rest[0] == '\u20e3'
+	case !unicode.Is(unicode.So, first):
+		return false
+	}
+
+	for i, r := range runes[1:] {
+		switch {
+		case i == 0 && (r == '\ufe0f' || (r >= 0x1f3fb && r <= 0x1f3ff)):
+			// A variation selector or skin tone right after the symbol
+		case r >= 0xe0020 && r <= 0xe007f:
+			// Tag characters used by subdivision flags
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// Tells clients a user's custom status changed. A nil status means it was cleared.
+// Users who blocked them aren't told.
+func broadcastCustomStatus(ctx context.Context, uid string, status *models.CustomStatus) {
+	recipients, err := broadcastRecipients(ctx, uid)
+	if err != nil {
+		log.Println("error broadcasting status:", err)
+		return
+	}
+
+	broadcastTo(recipients, "custom_status_changed", map[string]any{
+		"user_id":       uid,
+		"custom_status": status,
+	})
+}
+
+// Clears a user's status if it has expired and tells clients. Only the caller whose
+// update succeeds emits the event, and a status set since it was found is kept.
+func clearExpiredStatus(ctx context.Context, id primitive.ObjectID) {
+	db := config.MongoClient()
+
+	filter := bson.M{"_id": id, "custom_status.expires_at": bson.M{"$lte": primitive.NewDateTimeFromTime(time.Now())}}
+	err := db.Database("Chat-App").Collection("users").FindOneAndUpdate(ctx, filter, bson.M{"$unset": bson.M{"custom_status": ""}}).Err()
+	if err == mongo.ErrNoDocuments {
+		return
+	}
+	if err != nil {
+		log.Println("error clearing expired status:", err)
+		return
+	}
+
+	broadcastCustomStatus(ctx, id.Hex(), nil)
+}
+
+// Clears expired custom statuses until the context is cancelled.
+func StartStatusReaper(ctx context.Context) {
+	db := config.MongoClient()
+
+	ticker := time.NewTicker(statusReaperInterval)
+	defer ticker.Stop()
+
+	for {
+		filter := bson.M{"custom_status.expires_at": bson.M{"$lte": primitive.NewDateTimeFromTime(time.Now())}}
+		cursor, err := db.Database("Chat-App").Collection("users").Find(ctx, filter,
+			options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(500))
+		if err != nil {
+			log.Println("error fetching expired statuses:", err)
+		} else {
+			var expired []models.User
+			if err := cursor.All(ctx, &expired); err != nil {
+				log.Println("error fetching expired statuses:", err)
+			}
+			for _, user := range expired {
+				clearExpiredStatus(ctx, user.ID)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
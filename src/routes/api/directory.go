@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	minDirectoryQuery   = 2
+	maxDirectoryQuery   = 64
+	maxDirectoryResults = 50
+)
+
+// Matches users whose username starts with the query, or whose display name has a word
+// starting with each word of the query. Both are anchored regexes, so they can use the
+// indexes on username_search_key and display_name_terms. Usernames are matched without
+// folding lookalikes, which would turn "bern" into "bem".
+func directoryMatchFilter(query string) bson.A {
+	var match bson.A
+	if key := util.UsernameSearchKey(strings.TrimPrefix(query, "@")); key != "" {
+		match = append(match, bson.M{"username_search_key": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(key)}})
+	}
+
+	if terms := util.SearchTerms(query); len(terms) > 0 {
+		prefixes := make(bson.A, 0, len(terms))
+		for _, term := range terms {
+			prefixes = append(prefixes, primitive.Regex{Pattern: "^" + regexp.QuoteMeta(term)})
+		}
+		match = append(match, bson.M{"display_name_terms": bson.M{"$all": prefixes}})
+	}
+	return match
+}
+
+// Searches users by username prefix or display name, ordered by username. Pass the
+// last username of a page as after to get the next one. Users who hide themselves from
+// the directory, disabled accounts and users blocked either way are left out.
+func HandleSearchUsers(c *gin.Context) {
+	db := config.MongoClient()
+
+	query := strings.TrimSpace(c.Query("q"))
+	if length := len([]rune(query)); length < minDirectoryQuery || length > maxDirectoryQuery {
+		c.JSON(400, gin.H{"status": "error", "message": "Search query must be between 2 and 64 characters"})
+		return
+	}
+	match := directoryMatchFilter(query)
+	if len(match) == 0 {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid search query"})
+		return
+	}
+
+	limit := 20
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(400, gin.H{"status": "error", "message": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, maxDirectoryResults)
+	}
+
+	uid := util.GetUid(c)
+	blocked, err := blockedIDs(c, uid)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to search users"})
+		return
+	}
+	blockers, err := blockerIDs(c, uid, models.BlockKindBlock)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to search users"})
+		return
+	}
+	friends, err := friendIDs(c, uid)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to search users"})
+		return
+	}
+
+	viewerID, _ := primitive.ObjectIDFromHex(uid)
+	filter := bson.M{
+		"$or":                   match,
+		"_id":                   bson.M{"$nin": objectIDsFromHex(append(blocked, blockers...))},
+		"disabled":              bson.M{"$ne": true},
+		"deletion_scheduled_at": bson.M{"$exists": false},
+		"$and": bson.A{bson.M{"$or": bson.A{
+			bson.M{"profile.visibility.directory": bson.M{"$in": bson.A{nil, models.VisibilityPublic}}},
+			bson.M{"profile.visibility.directory": models.VisibilityContacts, "_id": bson.M{"$in": objectIDsFromHex(friends)}},
+			bson.M{"_id": viewerID},
+		}}},
+	}
+	// Paged by the key username prefixes are matched on, so the username_search_key
+	// index serves both the match and the order
+	if after := c.Query("after"); after != "" {
+		filter["username_search_key"] = bson.M{"$gt": after}
+	}
+
+	findOptions := options.Find().SetSort(bson.M{"username_search_key": 1}).SetLimit(int64(limit))
+	cursor, err := db.Database("Chat-App").Collection("users").Find(c, filter, findOptions)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to search users"})
+		return
+	}
+	var users []models.User
+	if err := cursor.All(c, &users); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to search users"})
+		return
+	}
+
+	results := make([]UserProfileResponse, 0, len(users))
+	for _, user := range users {
+		results = append(results, profileResponseForContact(user, uid, slices.Contains(friends, user.ID.Hex())))
+	}
+
+	response := gin.H{"status": "success", "users": results}
+	if len(users) == limit {
+		response["next"] = users[len(users)-1].UsernameSearchKey
+	}
+	c.JSON(200, response)
+}
+
+func DirectoryRoutes(route *gin.RouterGroup) {
+	directoryGroup := route.Group("/")
+	{
+		directoryGroup.GET("search_users", middlewares.AuthenticateAccessToken(models.ScopeProfileRead), HandleSearchUsers)
+	}
+}
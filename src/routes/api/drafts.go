@@ -0,0 +1,230 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"context"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SaveDraft struct {
+	ChannelId string `json:"channel_id"`
+	Content   string `json:"content" validate:"max=10000"`
+	UpdatedAt string `json:"updated_at" validate:"required"`
+}
+
+type DeleteDraft struct {
+	ChannelId string `json:"channel_id"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type DraftResponse struct {
+	ChannelID string             `json:"channel_id"`
+	Content   string             `json:"content"`
+	UpdatedAt primitive.DateTime `json:"updated_at"`
+}
+
+func toDraftResponse(draft models.Draft) DraftResponse {
+	return DraftResponse{
+		ChannelID: draft.ChannelID,
+		Content:   draft.Content,
+		UpdatedAt: draft.UpdatedAt,
+	}
+}
+
+// Parses a client timestamp, clamped to the server's clock so a device whose clock runs
+// ahead can't write drafts dated in the future.
+func parseDraftTime(value string) (primitive.DateTime, error) {
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return 0, err
+	}
+
+	if now := time.Now(); parsed.After(now) {
+		parsed = now
+	}
+	return primitive.NewDateTimeFromTime(parsed), nil
+}
+
+// Deletes the user's draft for the channel unless it was written after the given time.
+func deleteDraft(ctx context.Context, uid string, channelID string, before primitive.DateTime) error {
+	db := config.MongoClient()
+
+	filter := bson.M{"user_id": uid, "channel_id": channelID, "updated_at": bson.M{"$lte": before}}
+	_, err := db.Database("Chat-App").Collection("drafts").DeleteOne(ctx, filter)
+	return err
+}
+
+// Removes the draft a message was composed from once the message is sent, whatever
+// its timestamp.
+func clearDraftAfterSend(ctx context.Context, message models.Message) {
+	db := config.MongoClient()
+
+	filter := bson.M{"user_id": message.SenderID, "channel_id": message.ChannelID}
+	_, err := db.Database("Chat-App").Collection("drafts").DeleteOne(ctx, filter)
+	if err != nil {
+		log.Println("error deleting draft:", err)
+	}
+}
+
+func HandleGetDrafts(c *gin.Context) {
+	db := config.MongoClient()
+
+	uid := util.GetUid(c)
+	cursor, err := db.Database("Chat-App").Collection("drafts").Find(c, bson.M{"user_id": uid}, options.Find().SetSort(bson.M{"updated_at": -1}))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch drafts"})
+		return
+	}
+	defer cursor.Close(c)
+
+	drafts := []DraftResponse{}
+	for cursor.Next(c) {
+		var draft models.Draft
+		if err := cursor.Decode(&draft); err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch drafts"})
+			return
+		}
+
+		drafts = append(drafts, toDraftResponse(draft))
+	}
+
+	if err := cursor.Err(); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch drafts"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "drafts": drafts})
+}
+
+func HandleGetDraft(c *gin.Context) {
+	db := config.MongoClient()
+
+	var draft models.Draft
+	filter := bson.M{"user_id": util.GetUid(c), "channel_id": c.Param("channel_id")}
+	err := db.Database("Chat-App").Collection("drafts").FindOne(c, filter).Decode(&draft)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(404, gin.H{"status": "error", "message": "Draft not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch draft"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "draft": toDraftResponse(draft)})
+}
+
+func HandleSaveDraft(c *gin.Context) {
+	db := config.MongoClient()
+
+	var saveDraft SaveDraft
+
+	// Validate json structure
+	err := c.BindJSON(&saveDraft)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(saveDraft)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	updatedAt, err := parseDraftTime(saveDraft.UpdatedAt)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "updated_at must be an RFC 3339 date"})
+		return
+	}
+
+	uid := util.GetUid(c)
+	channelID, ok := resolveChannelID(uid, saveDraft.ChannelId)
+	if !ok {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid channel ID"})
+		return
+	}
+
+	// Last write wins: the filter only matches an older draft, so when a newer one is
+	// stored the upsert collides with the unique index and the write is dropped.
+	draft := models.Draft{
+		UserID:    uid,
+		ChannelID: channelID,
+		Content:   saveDraft.Content,
+		UpdatedAt: updatedAt,
+	}
+	filter := bson.M{"user_id": uid, "channel_id": channelID, "updated_at": bson.M{"$lt": draft.UpdatedAt}}
+	update := bson.M{"$set": bson.M{"content": draft.Content, "updated_at": draft.UpdatedAt}}
+	_, err = db.Database("Chat-App").Collection("drafts").UpdateOne(c, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		var current models.Draft
+		err = db.Database("Chat-App").Collection("drafts").FindOne(c, bson.M{"user_id": uid, "channel_id": channelID}).Decode(&current)
+		if err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to save draft"})
+			return
+		}
+
+		c.JSON(409, gin.H{"status": "error", "message": "A newer draft already exists", "draft": toDraftResponse(current)})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to save draft"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Draft saved successfully", "draft": toDraftResponse(draft)})
+}
+
+func HandleDeleteDraft(c *gin.Context) {
+	var deleteDraftBody DeleteDraft
+
+	err := c.BindJSON(&deleteDraftBody)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	// Without a timestamp the draft is deleted whatever its age
+	before := primitive.NewDateTimeFromTime(time.Now())
+	if deleteDraftBody.UpdatedAt != "" {
+		before, err = parseDraftTime(deleteDraftBody.UpdatedAt)
+		if err != nil {
+			c.JSON(400, gin.H{"status": "error", "message": "updated_at must be an RFC 3339 date"})
+			return
+		}
+	}
+
+	uid := util.GetUid(c)
+	channelID, ok := resolveChannelID(uid, deleteDraftBody.ChannelId)
+	if !ok {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid channel ID"})
+		return
+	}
+
+	err = deleteDraft(c, uid, channelID, before)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to delete draft"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Draft deleted successfully"})
+}
+
+func DraftRoutes(route *gin.RouterGroup) {
+	draftGroup := route.Group("/")
+	{
+		draftGroup.GET("drafts", middlewares.AuthenticateAccessToken(), HandleGetDrafts)
+		draftGroup.GET("draft/:channel_id", middlewares.AuthenticateAccessToken(), HandleGetDraft)
+		draftGroup.POST("save_draft", middlewares.AuthenticateAccessToken(), HandleSaveDraft)
+		draftGroup.POST("delete_draft", middlewares.AuthenticateAccessToken(), HandleDeleteDraft)
+	}
+}
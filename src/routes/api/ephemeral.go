@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"context"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MarkRead struct {
+	MessageIDs []string `json:"message_ids" validate:"required,min=1,max=100"`
+}
+
+const reaperInterval = 30 * time.Second
+
+// Counts the recipients of the message who haven't read it yet, not counting the sender.
+// Reads from users who aren't recipients are ignored, so they can't expire it early.
+func unreadRecipientCount(ctx context.Context, message models.Message) (int64, error) {
+	db := config.MongoClient()
+
+	blockers, err := blockerIDs(ctx, message.SenderID, models.BlockKindBlock)
+	if err != nil {
+		return 0, err
+	}
+
+	// Every user is a member of the default channel. The sender of an anonymized
+	// message no longer exists, so everyone is a recipient. Bots and disabled users
+	// aren't expected to read messages, and users who blocked the sender never see
+	// the message, so any of them would keep it from ever expiring.
+	excluded := append(append([]string{message.SenderID}, message.ReadBy...), blockers...)
+	filter := bson.M{
+		"_id":      bson.M{"$nin": objectIDsFromHex(excluded)},
+		"bot":      bson.M{"$ne": true},
+		"disabled": bson.M{"$ne": true},
+	}
+
+	return db.Database("Chat-App").Collection("users").CountDocuments(ctx, filter)
+}
+
+// Deletes an expired message, purges its attachments and tells clients to remove it.
+// Only the caller whose delete succeeds emits the event, so several instances can reap at once.
+func reapMessage(ctx context.Context, id primitive.ObjectID) {
+	db := config.MongoClient()
+
+	var message models.Message
+	filter := bson.M{"_id": id, "expires_at": bson.M{"$lte": primitive.NewDateTimeFromTime(time.Now())}}
+	err := db.Database("Chat-App").Collection("messages").FindOneAndDelete(ctx, filter).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		return
+	}
+	if err != nil {
+		log.Println("error deleting expired message:", err)
+		return
+	}
+
+	announceMessageDeleted(message)
+}
+
+// Purges the attachments of a deleted message and tells clients to remove it.
+func announceMessageDeleted(message models.Message) {
+	for _, attachment := range message.Attachments {
+		if err := config.Storage().Delete(attachment.Key); err != nil {
+			log.Println("error deleting attachment:", err)
+		}
+	}
+
+	broadcast("message_deleted", map[string]any{
+		"id":         message.ID.Hex(),
+		"channel_id": message.ChannelID,
+	})
+}
+
+func HandleMarkRead(c *gin.Context) {
+	db := config.MongoClient()
+
+	var markRead MarkRead
+
+	// Validate json structure
+	err := c.BindJSON(&markRead)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(markRead)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	uid := util.GetUid(c)
+	for _, messageID := range markRead.MessageIDs {
+		objectID, err := primitive.ObjectIDFromHex(messageID)
+		if err != nil {
+			c.JSON(400, gin.H{"status": "error", "message": "Invalid message ID"})
+			return
+		}
+
+		// Record the read on messages that expire once everyone has read them
+		var message models.Message
+		filter := bson.M{"_id": objectID, "expire_after_read": true, "sender_id": bson.M{"$ne": uid}}
+		err = db.Database("Chat-App").Collection("messages").FindOneAndUpdate(c, filter,
+			bson.M{"$addToSet": bson.M{"read_by": uid}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&message)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to mark messages as read"})
+			return
+		}
+
+		unread, err := unreadRecipientCount(c, message)
+		if err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to mark messages as read"})
+			return
+		}
+		if unread > 0 {
+			continue
+		}
+
+		// Everyone has read it, expire it now
+		_, err = db.Database("Chat-App").Collection("messages").UpdateOne(c, bson.M{"_id": objectID},
+			bson.M{"$min": bson.M{"expires_at": primitive.NewDateTimeFromTime(time.Now())}})
+		if err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to mark messages as read"})
+			return
+		}
+		reapMessage(c, objectID)
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Messages marked as read"})
+}
+
+// Deletes expired messages until the context is cancelled.
+func StartMessageReaper(ctx context.Context) {
+	db := config.MongoClient()
+
+	ticker := time.NewTicker(reaperInterval)
+	defer ticker.Stop()
+
+	for {
+		filter := bson.M{"expires_at": bson.M{"$lte": primitive.NewDateTimeFromTime(time.Now())}}
+		cursor, err := db.Database("Chat-App").Collection("messages").Find(ctx, filter,
+			options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(500))
+		if err != nil {
+			log.Println("error fetching expired messages:", err)
+		} else {
+			var expired []models.Message
+			if err := cursor.All(ctx, &expired); err != nil {
+				log.Println("error fetching expired messages:", err)
+			}
+			for _, message := range expired {
+				reapMessage(ctx, message.ID)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func EphemeralMessageRoutes(route *gin.RouterGroup) {
+	ephemeralGroup := route.Group("/")
+	{
+		ephemeralGroup.POST("mark_read", middlewares.AuthenticateAccessToken(), HandleMarkRead)
+	}
+}
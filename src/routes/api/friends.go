@@ -0,0 +1,409 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type UserAction struct {
+	UserID string `json:"user_id" validate:"required"`
+}
+
+type FriendResponse struct {
+	UserProfileResponse
+	Presence     string             `json:"presence"`
+	FriendsSince primitive.DateTime `json:"friends_since"`
+}
+
+type FriendRequestResponse struct {
+	ID        string              `json:"id"`
+	User      UserProfileResponse `json:"user"`
+	CreatedAt primitive.DateTime  `json:"created_at"`
+}
+
+// Identifies the pair of users the same way whichever of them is first.
+func friendshipPairKey(uid string, otherID string) string {
+	if uid > otherID {
+		uid, otherID = otherID, uid
+	}
+	return uid + ":" + otherID
+}
+
+func areFriends(ctx context.Context, uid string, otherID string) (bool, error) {
+	db := config.MongoClient()
+
+	count, err := db.Database("Chat-App").Collection("friendships").CountDocuments(ctx,
+		bson.M{"pair_key": friendshipPairKey(uid, otherID), "status": models.FriendshipAccepted})
+	return count > 0, err
+}
+
+// Returns the IDs of the user's friends.
+func friendIDs(ctx context.Context, uid string) ([]string, error) {
+	db := config.MongoClient()
+
+	cursor, err := db.Database("Chat-App").Collection("friendships").Find(ctx,
+		bson.M{"user_ids": uid, "status": models.FriendshipAccepted})
+	if err != nil {
+		return nil, err
+	}
+	var friendships []models.Friendship
+	if err := cursor.All(ctx, &friendships); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(friendships))
+	for _, friendship := range friendships {
+		ids = append(ids, friendshipOther(friendship, uid))
+	}
+	return ids, nil
+}
+
+// The user on the other side of the friendship.
+func friendshipOther(friendship models.Friendship, uid string) string {
+	if friendship.RequesterID == uid {
+		return friendship.AddresseeID
+	}
+	return friendship.RequesterID
+}
+
+// Converts user IDs to object IDs, skipping invalid ones.
+func objectIDsFromHex(ids []string) []primitive.ObjectID {
+	objectIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objectIDs = append(objectIDs, objectID)
+		}
+	}
+	return objectIDs
+}
+
+// Loads users by their IDs, keyed by ID.
+func fetchUsersByID(ctx context.Context, ids []string) (map[string]models.User, error) {
+	db := config.MongoClient()
+
+	cursor, err := db.Database("Chat-App").Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": objectIDsFromHex(ids)}})
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]models.User, len(users))
+	for _, user := range users {
+		byID[user.ID.Hex()] = user
+	}
+	return byID, nil
+}
+
+// Tells both users about a change to their friendship.
+func notifyFriendship(event string, friendship models.Friendship) {
+	notifyUsers([]string{friendship.RequesterID, friendship.AddresseeID}, event, map[string]any{
+		"id":           friendship.ID.Hex(),
+		"requester_id": friendship.RequesterID,
+		"addressee_id": friendship.AddresseeID,
+		"status":       friendship.Status,
+	})
+}
+
+// Binds the user the action is about. Responds and returns false when the request is invalid.
+func bindUserAction(c *gin.Context) (string, bool) {
+	var userAction UserAction
+
+	// Validate json structure
+	err := c.BindJSON(&userAction)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return "", false
+	}
+	err = validate.Struct(userAction)
+	if err != nil || !primitive.IsValidObjectID(userAction.UserID) {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return "", false
+	}
+
+	return userAction.UserID, true
+}
+
+// Sends a friend request. When the other user already asked to be friends, their
+// request is accepted instead.
+func HandleSendFriendRequest(c *gin.Context) {
+	db := config.MongoClient()
+
+	otherID, ok := bindUserAction(c)
+	if !ok {
+		return
+	}
+	uid := util.GetUid(c)
+	if otherID == uid {
+		c.JSON(400, gin.H{"status": "error", "message": "You can't befriend yourself"})
+		return
+	}
+
+	objectID, _ := primitive.ObjectIDFromHex(otherID)
+	var other models.User
+	err := db.Database("Chat-App").Collection("users").FindOne(c, bson.M{"_id": objectID}).Decode(&other)
+	if err != nil || other.Disabled || other.Bot {
+		c.JSON(404, gin.H{"status": "error", "message": "User not found"})
+		return
+	}
+
+	blocked, err := blockedEitherWay(c, uid, otherID)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to send friend request"})
+		return
+	}
+	if blocked {
+		c.JSON(403, gin.H{"status": "error", "message": "You can't send a friend request to this user"})
+		return
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	pairKey := friendshipPairKey(uid, otherID)
+
+	// Accept a pending request from the other user
+	var friendship models.Friendship
+	err = db.Database("Chat-App").Collection("friendships").FindOneAndUpdate(c,
+		bson.M{"pair_key": pairKey, "requester_id": otherID, "status": models.FriendshipPending},
+		bson.M{"$set": bson.M{"status": models.FriendshipAccepted, "accepted_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&friendship)
+	if err == nil {
+		notifyFriendship("friend_request_accepted", friendship)
+		c.JSON(200, gin.H{"status": "success", "message": "Friend request accepted"})
+		return
+	}
+	if err != mongo.ErrNoDocuments {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to send friend request"})
+		return
+	}
+
+	friendship = models.Friendship{
+		ID:          primitive.NewObjectID(),
+		PairKey:     pairKey,
+		UserIDs:     []string{uid, otherID},
+		RequesterID: uid,
+		AddresseeID: otherID,
+		Status:      models.FriendshipPending,
+		CreatedAt:   now,
+	}
+	_, err = db.Database("Chat-App").Collection("friendships").InsertOne(c, friendship)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(409, gin.H{"status": "error", "message": "Already friends or a friend request is pending"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to send friend request"})
+		return
+	}
+
+	notifyFriendship("friend_request_received", friendship)
+	c.JSON(200, gin.H{"status": "success", "message": "Friend request sent", "id": friendship.ID.Hex()})
+}
+
+func HandleAcceptFriendRequest(c *gin.Context) {
+	db := config.MongoClient()
+
+	requesterID, ok := bindUserAction(c)
+	if !ok {
+		return
+	}
+	uid := util.GetUid(c)
+
+	var friendship models.Friendship
+	err := db.Database("Chat-App").Collection("friendships").FindOneAndUpdate(c,
+		bson.M{"pair_key": friendshipPairKey(uid, requesterID), "addressee_id": uid, "status": models.FriendshipPending},
+		bson.M{"$set": bson.M{"status": models.FriendshipAccepted, "accepted_at": primitive.NewDateTimeFromTime(time.Now())}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&friendship)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(404, gin.H{"status": "error", "message": "Friend request not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to accept friend request"})
+		return
+	}
+
+	notifyFriendship("friend_request_accepted", friendship)
+	c.JSON(200, gin.H{"status": "success", "message": "Friend request accepted"})
+}
+
+// Deletes the friendship matching the filter and notifies both users. Responds with
+// notFound when there is none.
+func deleteFriendship(c *gin.Context, filter bson.M, event string, message string, notFound string) {
+	db := config.MongoClient()
+
+	var friendship models.Friendship
+	err := db.Database("Chat-App").Collection("friendships").FindOneAndDelete(c, filter).Decode(&friendship)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(404, gin.H{"status": "error", "message": notFound})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to update friends"})
+		return
+	}
+
+	notifyFriendship(event, friendship)
+	c.JSON(200, gin.H{"status": "success", "message": message})
+}
+
+func HandleDeclineFriendRequest(c *gin.Context) {
+	requesterID, ok := bindUserAction(c)
+	if !ok {
+		return
+	}
+	uid := util.GetUid(c)
+
+	filter := bson.M{"pair_key": friendshipPairKey(uid, requesterID), "addressee_id": uid, "status": models.FriendshipPending}
+	deleteFriendship(c, filter, "friend_request_declined", "Friend request declined", "Friend request not found")
+}
+
+func HandleCancelFriendRequest(c *gin.Context) {
+	addresseeID, ok := bindUserAction(c)
+	if !ok {
+		return
+	}
+	uid := util.GetUid(c)
+
+	filter := bson.M{"pair_key": friendshipPairKey(uid, addresseeID), "requester_id": uid, "status": models.FriendshipPending}
+	deleteFriendship(c, filter, "friend_request_cancelled", "Friend request cancelled", "Friend request not found")
+}
+
+func HandleRemoveFriend(c *gin.Context) {
+	friendID, ok := bindUserAction(c)
+	if !ok {
+		return
+	}
+	uid := util.GetUid(c)
+
+	filter := bson.M{"pair_key": friendshipPairKey(uid, friendID), "status": models.FriendshipAccepted}
+	deleteFriendship(c, filter, "friend_removed", "Friend removed", "Friend not found")
+}
+
+// Lists the user's friends with whether they are online.
+func HandleGetFriends(c *gin.Context) {
+	db := config.MongoClient()
+
+	uid := util.GetUid(c)
+	cursor, err := db.Database("Chat-App").Collection("friendships").Find(c,
+		bson.M{"user_ids": uid, "status": models.FriendshipAccepted},
+		options.Find().SetSort(bson.M{"accepted_at": -1}))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch friends"})
+		return
+	}
+	var friendships []models.Friendship
+	if err := cursor.All(c, &friendships); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch friends"})
+		return
+	}
+
+	ids := make([]string, 0, len(friendships))
+	for _, friendship := range friendships {
+		ids = append(ids, friendshipOther(friendship, uid))
+	}
+	users, err := fetchUsersByID(c, ids)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch friends"})
+		return
+	}
+
+	friends := []FriendResponse{}
+	for _, friendship := range friendships {
+		user, ok := users[friendshipOther(friendship, uid)]
+		if !ok {
+			continue
+		}
+
+		presence := user.Status
+		if presence == "" {
+			presence = models.StatusOffline
+		}
+		since := friendship.CreatedAt
+		if friendship.AcceptedAt != nil {
+			since = *friendship.AcceptedAt
+		}
+		friends = append(friends, FriendResponse{
+			UserProfileResponse: profileResponseForContact(user, uid, true),
+			Presence:            presence,
+			FriendsSince:        since,
+		})
+	}
+
+	c.JSON(200, gin.H{"status": "success", "friends": friends})
+}
+
+// Lists the pending friend requests the user has received and sent.
+func HandleGetFriendRequests(c *gin.Context) {
+	db := config.MongoClient()
+
+	uid := util.GetUid(c)
+	cursor, err := db.Database("Chat-App").Collection("friendships").Find(c,
+		bson.M{"user_ids": uid, "status": models.FriendshipPending},
+		options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch friend requests"})
+		return
+	}
+	var friendships []models.Friendship
+	if err := cursor.All(c, &friendships); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch friend requests"})
+		return
+	}
+
+	ids := make([]string, 0, len(friendships))
+	for _, friendship := range friendships {
+		ids = append(ids, friendshipOther(friendship, uid))
+	}
+	users, err := fetchUsersByID(c, ids)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch friend requests"})
+		return
+	}
+
+	incoming := []FriendRequestResponse{}
+	outgoing := []FriendRequestResponse{}
+	for _, friendship := range friendships {
+		user, ok := users[friendshipOther(friendship, uid)]
+		if !ok {
+			continue
+		}
+
+		request := FriendRequestResponse{
+			ID:        friendship.ID.Hex(),
+			User:      profileResponseForContact(user, uid, false),
+			CreatedAt: friendship.CreatedAt,
+		}
+		if friendship.AddresseeID == uid {
+			incoming = append(incoming, request)
+		} else {
+			outgoing = append(outgoing, request)
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "incoming": incoming, "outgoing": outgoing})
+}
+
+func FriendRoutes(route *gin.RouterGroup) {
+	friendGroup := route.Group("/")
+	{
+		friendGroup.POST("send_friend_request", middlewares.AuthenticateAccessToken(), HandleSendFriendRequest)
+		friendGroup.POST("accept_friend_request", middlewares.AuthenticateAccessToken(), HandleAcceptFriendRequest)
+		friendGroup.POST("decline_friend_request", middlewares.AuthenticateAccessToken(), HandleDeclineFriendRequest)
+		friendGroup.POST("cancel_friend_request", middlewares.AuthenticateAccessToken(), HandleCancelFriendRequest)
+		friendGroup.POST("remove_friend", middlewares.AuthenticateAccessToken(), HandleRemoveFriend)
+		friendGroup.GET("friends", middlewares.AuthenticateAccessToken(), HandleGetFriends)
+		friendGroup.GET("friend_requests", middlewares.AuthenticateAccessToken(), HandleGetFriendRequests)
+	}
+}
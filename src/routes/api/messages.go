@@ -5,7 +5,10 @@ import (
 	"chat-app-back/src/middlewares"
 	"chat-app-back/src/models"
 	"chat-app-back/src/util"
-	"fmt"
+	"context"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -16,8 +19,10 @@ import (
 )
 
 type GetMessageContent struct {
-	Content   string `json:"content"`
-	ChannelId string `json:"channel_id"`
+	Content         string `json:"content"`
+	ChannelId       string `json:"channel_id"`
+	TTLSeconds      int    `json:"ttl_seconds" validate:"omitempty,min=5,max=604800"`
+	ExpireAfterRead bool   `json:"expire_after_read"`
 }
 
 type ChannelCreate struct {
@@ -29,21 +34,222 @@ type MessageUser struct {
 	ID             string `json:"id"`
 	Username       string `json:"username"`
 	ProfilePicture string `json:"profile_picture"`
+	Bot            bool   `json:"bot"`
+	Deleted        bool   `json:"deleted,omitempty"`
+}
+
+// A message with its author joined from the users collection.
+type lookedUpMessage struct {
+	models.Message `bson:",inline"`
+	User           []models.User `bson:"user"`
 }
 
 type MessageContent struct {
-	ID        string             `json:"id"`
-	SenderID  string             `json:"sender_id"`
-	CreatedAt primitive.DateTime `json:"created_at"`
-	Content   string             `json:"content"`
-	User      MessageUser        `json:"user"`
+	ID              string              `json:"id"`
+	SenderID        string              `json:"sender_id"`
+	CreatedAt       primitive.DateTime  `json:"created_at"`
+	Type            string              `json:"type"`
+	Content         string              `json:"content"`
+	Poll            *PollResponse       `json:"poll,omitempty"`
+	User            MessageUser         `json:"user"`
+	ExpiresAt       *primitive.DateTime `json:"expires_at,omitempty"`
+	ExpireAfterRead bool                `json:"expire_after_read,omitempty"`
 }
 
 var validate = validator.New()
 
+// Every user is a member of the global chat channel.
+const defaultChannelID = "main"
+
+var mentionPattern = regexp.MustCompile(`@([\p{L}\p{N}_.-]+)`)
+
+// Returns the channels the user is allowed to read.
+func userChannelIDs(uid string) []string {
+	return []string{defaultChannelID}
+}
+
+// Returns the channel a message should be posted to, falling back to the default
+// channel, and whether the user is allowed to post there.
+func resolveChannelID(uid string, channelID string) (string, bool) {
+	if channelID == "" {
+		channelID = defaultChannelID
+	}
+
+	return channelID, slices.Contains(userChannelIDs(uid), channelID)
+}
+
+// Builds a filter matching messages in the given channels. Messages stored before
+// channel ids were recorded have no channel_id and belong to the default channel.
+func channelFilter(channelIDs []string) bson.M {
+	values := bson.A{}
+	for _, id := range channelIDs {
+		values = append(values, id)
+		if id == defaultChannelID {
+			values = append(values, nil)
+		}
+	}
+
+	return bson.M{"channel_id": bson.M{"$in": values}}
+}
+
+// Messages stored before message types existed are text messages.
+func messageType(message models.Message) string {
+	if message.Type == "" {
+		return models.MessageTypeText
+	}
+
+	return message.Type
+}
+
+// Matches messages that have not expired yet. Expired messages stay in the
+// collection until the reaper deletes them, so reads have to skip them.
+func notExpiredFilter() bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"expires_at": bson.M{"$exists": false}},
+		bson.M{"expires_at": bson.M{"$gt": primitive.NewDateTimeFromTime(time.Now())}},
+	}}
+}
+
+// Pipeline stages joining the author of each message as "user". Senders that are not
+// user ids, such as the placeholder of anonymized messages, join nobody.
+func senderLookupStages() mongo.Pipeline {
+	return mongo.Pipeline{
+		bson.D{{Key: "$addFields", Value: bson.M{"sender_id_object": bson.M{
+			"$convert": bson.M{"input": "$sender_id", "to": "objectId", "onError": nil, "onNull": nil},
+		}}}},
+		bson.D{{
+			Key: "$lookup", Value: bson.M{
+				"from":         "users",            // The other collection
+				"localField":   "sender_id_object", // Name of the field in messages collection
+				"foreignField": "_id",              // Name of the field in users collection
+				"as":           "user",             // Output array field
+			},
+		}},
+	}
+}
+
+// Builds the author shown with a message from the users joined by senderLookupStages.
+// Authors who deleted their account are not found and are shown as deleted.
+func messageUserFromLookup(users []models.User) MessageUser {
+	if len(users) == 0 {
+		return MessageUser{Username: deletedUsername, Deleted: true}
+	}
+
+	user := MessageUser{
+		ID:       users[0].ID.Hex(),
+		Username: users[0].Username,
+		Bot:      users[0].Bot,
+	}
+	if users[0].ProfilePicture != nil {
+		user.ProfilePicture = *users[0].ProfilePicture
+	}
+
+	return user
+}
+
+// Resolves @username mentions in the content to user ids.
+func extractMentions(ctx context.Context, content string) []string {
+	matches := mentionPattern.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	// Mentions match usernames the way uniqueness does, ignoring trailing punctuation
+	keys := make([]string, 0, len(matches))
+	for _, match := range matches {
+		keys = append(keys, util.UsernameKey(strings.TrimRight(match[1], "_.-")))
+	}
+
+	db := config.MongoClient()
+	cursor, err := db.Database("Chat-App").Collection("users").Find(ctx, bson.M{"username_key": bson.M{"$in": keys}})
+	if err != nil {
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var mentions []string
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			continue
+		}
+		mentions = append(mentions, user.ID.Hex())
+	}
+
+	return mentions
+}
+
+// Persists the message and broadcasts it to the channel. Used by HandleSendMessage
+// and the message scheduler so both go through the same path.
+func sendMessage(ctx context.Context, user models.User, message models.Message) error {
+	db := config.MongoClient()
+
+	// Users who blocked the sender must not receive the message
+	recipients, err := broadcastRecipients(ctx, message.SenderID)
+	if err != nil {
+		return err
+	}
+
+	// Mentions notify the mentioned users, unless they blocked or muted the sender
+	silenced, err := blockerIDs(ctx, message.SenderID, models.BlockKindBlock, models.BlockKindMute)
+	if err != nil {
+		return err
+	}
+	var notified []string
+	for _, mention := range message.Mentions {
+		if mention != message.SenderID && !slices.Contains(silenced, mention) {
+			notified = append(notified, mention)
+		}
+	}
+
+	_, err = db.Database("Chat-App").Collection("messages").InsertOne(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	// Bots and some accounts have no profile picture
+	profilePicture := ""
+	if user.ProfilePicture != nil {
+		profilePicture = *user.ProfilePicture
+	}
+
+	go func() {
+		// Trigger pusher event
+		data := map[string]any{
+			"id":                message.ID.Hex(),
+			"sender_id":         message.SenderID,
+			"me":                false,
+			"created_at":        message.CreatedAt,
+			"type":              message.Type,
+			"content":           message.Content,
+			"poll":              toPollResponse(message.Poll, ""),
+			"expires_at":        message.ExpiresAt,
+			"expire_after_read": message.ExpireAfterRead,
+			"user": map[string]any{
+				"id":              user.ID.Hex(),
+				"username":        user.Username,
+				"profile_picture": profilePicture,
+				"bot":             user.Bot,
+			},
+		}
+		broadcastTo(recipients, "main", data)
+
+		if len(notified) > 0 {
+			notifyUsers(notified, "mention", map[string]any{
+				"message_id": message.ID.Hex(),
+				"channel_id": message.ChannelID,
+				"sender_id":  message.SenderID,
+				"username":   user.Username,
+				"content":    message.Content,
+			})
+		}
+	}()
+
+	return nil
+}
+
 func HandleSendMessage(c *gin.Context) {
 	db := config.MongoClient()
-	pusherClient := config.PusherInit()
 
 	var messageContent GetMessageContent
 
@@ -77,61 +283,68 @@ func HandleSendMessage(c *gin.Context) {
 		return
 	}
 
+	channelID, ok := resolveChannelID(uid, messageContent.ChannelId)
+	if !ok {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid channel ID"})
+		return
+	}
+
 	message := models.Message{
 		ID:        primitive.NewObjectID(),
 		SenderID:  uid,
+		ChannelID: channelID,
+		Type:      models.MessageTypeText,
 		Content:   messageContent.Content,
+		Mentions:  extractMentions(c, messageContent.Content),
 		CreatedAt: primitive.NewDateTimeFromTime(time.Now())}
-	_, err = db.Database("Chat-App").Collection("messages").InsertOne(c, message)
 
+	if messageContent.TTLSeconds > 0 {
+		expiresAt := primitive.NewDateTimeFromTime(time.Now().Add(time.Duration(messageContent.TTLSeconds) * time.Second))
+		message.ExpiresAt = &expiresAt
+	}
+	message.ExpireAfterRead = messageContent.ExpireAfterRead
+
+	err = sendMessage(c, user, message)
 	if err != nil {
 		c.JSON(500, gin.H{"status": "error", "message": "Failed to send message"})
 		return
 	}
+	clearDraftAfterSend(c, message)
 
 	c.JSON(200, gin.H{"status": "success", "message": "Message sent successfully", "at": message.CreatedAt})
 
-	go func() {
-		// Trigger pusher event
-		data := map[string]any{
-			"id":         message.ID.Hex(),
-			"sender_id":  message.SenderID,
-			"me":         false,
-			"created_at": message.CreatedAt,
-			"content":    message.Content,
-			"user": map[string]string{
-				"id":              uid,
-				"username":        user.Username,
-				"profile_picture": *user.ProfilePicture,
-			},
-		}
-		err = pusherClient.Trigger("super-chat-channel", "main", data)
-		if err != nil {
-			fmt.Println(err.Error())
-		}
-
-		// Set offline status after 15 minutes
-		util.SetOfflineAfterDuration(uid, 15*time.Minute, c)
-	}()
+	// Set offline status after 15 minutes. Bots stay online
+	if !user.Bot {
+		go util.SetOfflineAfterDuration(uid, 15*time.Minute, c)
+	}
 }
 
 func HandleGetMessages(c *gin.Context) {
 	db := config.MongoClient()
 
 	// Create pipeline to fetch user data for messages
+	uid := util.GetUid(c)
+	filter := channelFilter(userChannelIDs(uid))
+	for key, value := range notExpiredFilter() {
+		filter[key] = value
+	}
+
+	// Leave out messages from blocked users
+	notBlocked, err := notBlockedFilter(c, uid)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch messages"})
+		return
+	}
+	for key, value := range notBlocked {
+		filter[key] = value
+	}
+
 	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: filter}},
 		bson.D{{Key: "$sort", Value: bson.M{"created_at": -1}}},
 		bson.D{{Key: "$limit", Value: 100}},
-		{{Key: "$addFields", Value: bson.M{"sender_id_object": bson.M{"$toObjectId": "$sender_id"}}}},
-		bson.D{{
-			Key: "$lookup", Value: bson.M{
-				"from":         "users",            // The other collection
-				"localField":   "sender_id_object", // Name of the field in messages collection
-				"foreignField": "_id",              // Name of the field in users collection
-				"as":           "user",             // Output array field
-			},
-		}},
 	}
+	pipeline = append(pipeline, senderLookupStages()...)
 
 	// Get all messages in the channel
 	cursor, err := db.Database("Chat-App").Collection("messages").Aggregate(c, pipeline)
@@ -140,28 +353,32 @@ func HandleGetMessages(c *gin.Context) {
 		return
 	}
 
-	var messages []MessageContent
+	defer cursor.Close(c)
 
+	messages := []MessageContent{}
 	for cursor.Next(c) {
-		var raw bson.M
-		if err := cursor.Decode(&raw); err != nil {
+		var message lookedUpMessage
+		if err := cursor.Decode(&message); err != nil {
 			c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch messages"})
 			return
 		}
 
-		messageContent := MessageContent{
-			ID:        raw["_id"].(primitive.ObjectID).Hex(),
-			SenderID:  raw["sender_id"].(string),
-			CreatedAt: raw["created_at"].(primitive.DateTime),
-			Content:   raw["content"].(string),
-			User: MessageUser{
-				ID:             raw["user"].(primitive.A)[0].(bson.M)["_id"].(primitive.ObjectID).Hex(),
-				Username:       raw["user"].(primitive.A)[0].(bson.M)["username"].(string),
-				ProfilePicture: raw["user"].(primitive.A)[0].(bson.M)["profile_picture"].(string),
-			},
-		}
+		messages = append(messages, MessageContent{
+			ID:              message.ID.Hex(),
+			SenderID:        message.SenderID,
+			CreatedAt:       message.CreatedAt,
+			Type:            messageType(message.Message),
+			Content:         message.Content,
+			Poll:            toPollResponse(message.Poll, uid),
+			User:            messageUserFromLookup(message.User),
+			ExpiresAt:       message.ExpiresAt,
+			ExpireAfterRead: message.ExpireAfterRead,
+		})
+	}
 
-		messages = append(messages, messageContent)
+	if err := cursor.Err(); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch messages"})
+		return
 	}
 
 	// Reverse the messages slice
@@ -175,7 +392,7 @@ func HandleGetMessages(c *gin.Context) {
 func MessageRoutes(route *gin.RouterGroup) {
 	messagesGroup := route.Group("/")
 	{
-		messagesGroup.POST("send_message", middlewares.AuthenticateAccessToken(), HandleSendMessage)
-		messagesGroup.GET("get_messages", middlewares.AuthenticateAccessToken(), HandleGetMessages)
+		messagesGroup.POST("send_message", middlewares.AuthenticateAccessToken(models.ScopeMessagesWrite), HandleSendMessage)
+		messagesGroup.GET("get_messages", middlewares.AuthenticateAccessToken(models.ScopeMessagesRead), HandleGetMessages)
 	}
 }
@@ -0,0 +1,269 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CreatePoll struct {
+	Question       string   `json:"question" validate:"required,max=300"`
+	Options        []string `json:"options" validate:"required,min=2,max=10,dive,required,max=100"`
+	MultipleChoice bool     `json:"multiple_choice"`
+	Anonymous      bool     `json:"anonymous"`
+	ClosesAt       string   `json:"closes_at"`
+	ChannelId      string   `json:"channel_id"`
+}
+
+type VotePoll struct {
+	OptionIDs []string `json:"option_ids"`
+}
+
+type PollOptionResponse struct {
+	ID     string   `json:"id"`
+	Text   string   `json:"text"`
+	Votes  int      `json:"votes"`
+	Voters []string `json:"voters,omitempty"`
+}
+
+type PollResponse struct {
+	Question       string               `json:"question"`
+	Options        []PollOptionResponse `json:"options"`
+	MultipleChoice bool                 `json:"multiple_choice"`
+	Anonymous      bool                 `json:"anonymous"`
+	ClosesAt       *primitive.DateTime  `json:"closes_at"`
+	Closed         bool                 `json:"closed"`
+	TotalVoters    int                  `json:"total_voters"`
+	MyVotes        []string             `json:"my_votes,omitempty"`
+}
+
+func pollClosed(poll *models.Poll) bool {
+	return poll.ClosesAt != nil && !poll.ClosesAt.Time().After(time.Now())
+}
+
+// Tallies the votes of a poll. Voters are only listed for public polls, and
+// MyVotes is filled in for the given user when uid is not empty.
+func toPollResponse(poll *models.Poll, uid string) *PollResponse {
+	if poll == nil {
+		return nil
+	}
+
+	response := &PollResponse{
+		Question:       poll.Question,
+		MultipleChoice: poll.MultipleChoice,
+		Anonymous:      poll.Anonymous,
+		ClosesAt:       poll.ClosesAt,
+		Closed:         pollClosed(poll),
+		TotalVoters:    len(poll.Votes),
+	}
+
+	for _, option := range poll.Options {
+		optionResponse := PollOptionResponse{ID: option.ID, Text: option.Text}
+		for voter, optionIDs := range poll.Votes {
+			if !slices.Contains(optionIDs, option.ID) {
+				continue
+			}
+
+			optionResponse.Votes++
+			if !poll.Anonymous {
+				optionResponse.Voters = append(optionResponse.Voters, voter)
+			}
+		}
+		slices.Sort(optionResponse.Voters)
+
+		response.Options = append(response.Options, optionResponse)
+	}
+
+	if uid != "" {
+		response.MyVotes = poll.Votes[uid]
+	}
+
+	return response
+}
+
+func HandleCreatePoll(c *gin.Context) {
+	db := config.MongoClient()
+
+	var createPoll CreatePoll
+
+	// Validate json structure
+	err := c.BindJSON(&createPoll)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(createPoll)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	poll := models.Poll{
+		Question:       createPoll.Question,
+		MultipleChoice: createPoll.MultipleChoice,
+		Anonymous:      createPoll.Anonymous,
+	}
+	for i, text := range createPoll.Options {
+		poll.Options = append(poll.Options, models.PollOption{ID: strconv.Itoa(i), Text: text})
+	}
+	if createPoll.ClosesAt != "" {
+		closesAt, err := time.Parse(time.RFC3339, createPoll.ClosesAt)
+		if err != nil || !closesAt.After(time.Now()) {
+			c.JSON(400, gin.H{"status": "error", "message": "closes_at must be a future RFC 3339 date"})
+			return
+		}
+		closesAtDate := primitive.NewDateTimeFromTime(closesAt)
+		poll.ClosesAt = &closesAtDate
+	}
+
+	uid := util.GetUid(c)
+	channelID, ok := resolveChannelID(uid, createPoll.ChannelId)
+	if !ok {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid channel ID"})
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(uid)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid user ID"})
+		return
+	}
+
+	// Fetch User data
+	var user models.User
+	err = db.Database("Chat-App").Collection("users").FindOne(c, bson.M{"_id": objectID}).Decode(&user)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to create poll"})
+		return
+	}
+
+	message := models.Message{
+		ID:        primitive.NewObjectID(),
+		SenderID:  uid,
+		ChannelID: channelID,
+		Type:      models.MessageTypePoll,
+		Content:   poll.Question,
+		Poll:      &poll,
+		Mentions:  extractMentions(c, poll.Question),
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	}
+
+	err = sendMessage(c, user, message)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to create poll"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Poll created successfully", "id": message.ID.Hex(), "at": message.CreatedAt})
+}
+
+func HandleVotePoll(c *gin.Context) {
+	db := config.MongoClient()
+
+	objectID, err := primitive.ObjectIDFromHex(c.Param("message_id"))
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid message ID"})
+		return
+	}
+
+	var votePoll VotePoll
+	err = c.BindJSON(&votePoll)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	uid := util.GetUid(c)
+
+	var message models.Message
+	filter := bson.M{"_id": objectID, "type": models.MessageTypePoll}
+	for key, value := range channelFilter(userChannelIDs(uid)) {
+		filter[key] = value
+	}
+	err = db.Database("Chat-App").Collection("messages").FindOne(c, filter).Decode(&message)
+	if err != nil {
+		c.JSON(404, gin.H{"status": "error", "message": "Poll not found"})
+		return
+	}
+
+	// Check the chosen options against the poll
+	optionIDs := slices.Clone(votePoll.OptionIDs)
+	slices.Sort(optionIDs)
+	optionIDs = slices.Compact(optionIDs)
+	if optionIDs == nil {
+		optionIDs = []string{}
+	}
+	if !message.Poll.MultipleChoice && len(optionIDs) > 1 {
+		c.JSON(400, gin.H{"status": "error", "message": "This poll only allows one choice"})
+		return
+	}
+	for _, optionID := range optionIDs {
+		valid := slices.ContainsFunc(message.Poll.Options, func(option models.PollOption) bool {
+			return option.ID == optionID
+		})
+		if !valid {
+			c.JSON(400, gin.H{"status": "error", "message": "Invalid option ID"})
+			return
+		}
+	}
+
+	// Replace the user's vote, or retract it when no options are given. The closing time is
+	// part of the filter so a vote racing the close is rejected.
+	voteKey := fmt.Sprintf("poll.votes.%s", uid)
+	update := bson.M{"$set": bson.M{voteKey: optionIDs}}
+	if len(optionIDs) == 0 {
+		update = bson.M{"$unset": bson.M{voteKey: ""}}
+	}
+	openFilter := bson.M{
+		"_id": objectID,
+		"$or": bson.A{
+			bson.M{"poll.closes_at": bson.M{"$exists": false}},
+			bson.M{"poll.closes_at": bson.M{"$gt": primitive.NewDateTimeFromTime(time.Now())}},
+		},
+	}
+
+	var updated models.Message
+	err = db.Database("Chat-App").Collection("messages").FindOneAndUpdate(c, openFilter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(409, gin.H{"status": "error", "message": "Poll is closed"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to vote"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Vote recorded successfully", "poll": toPollResponse(updated.Poll, uid)})
+
+	// Users who blocked the poll's sender don't see it change
+	recipients, err := broadcastRecipients(c, updated.SenderID)
+	if err != nil {
+		log.Println("error sending poll update:", err)
+		return
+	}
+	go broadcastTo(recipients, "poll_updated", map[string]any{
+		"id":   updated.ID.Hex(),
+		"poll": toPollResponse(updated.Poll, ""),
+	})
+}
+
+func PollRoutes(route *gin.RouterGroup) {
+	pollGroup := route.Group("/")
+	{
+		pollGroup.POST("create_poll", middlewares.AuthenticateAccessToken(), HandleCreatePoll)
+		pollGroup.POST("vote_poll/:message_id", middlewares.AuthenticateAccessToken(), HandleVotePoll)
+	}
+}
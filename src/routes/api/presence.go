@@ -0,0 +1,120 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/models"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type PresenceChange struct {
+	UserID string `json:"user_id"`
+	Status string `json:"status"`
+}
+
+const (
+	// How often queued presence changes are sent as a diff.
+	presenceFlushInterval = 5 * time.Second
+	// Keeps each presence_diff event well under Pusher's 10KB payload limit.
+	maxPresenceChanges = 100
+)
+
+var (
+	presenceMutex   sync.Mutex
+	pendingPresence = map[string]string{}
+)
+
+// Counts the users matching the filter by status.
+func presenceCounts(ctx context.Context, filter bson.M) (map[string]int, error) {
+	db := config.MongoClient()
+
+	pipeline := bson.A{
+		bson.M{"$match": filter},
+		bson.M{"$group": bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}},
+	}
+	cursor, err := db.Database("Chat-App").Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		Status string `bson:"_id"`
+		Count  int    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	// Users without a status are offline
+	counts := map[string]int{models.StatusOnline: 0, models.StatusOffline: 0}
+	for _, group := range groups {
+		status := group.Status
+		if status == "" {
+			status = models.StatusOffline
+		}
+		counts[status] += group.Count
+	}
+	return counts, nil
+}
+
+// Queues the user's new status for the next presence diff. Only their latest status
+// is sent, so a user who comes online and leaves between diffs just shows as offline.
+func QueuePresenceChange(uid string, status string) {
+	presenceMutex.Lock()
+	defer presenceMutex.Unlock()
+
+	pendingPresence[uid] = status
+}
+
+func takePresenceChanges() map[string]string {
+	presenceMutex.Lock()
+	defer presenceMutex.Unlock()
+
+	changes := pendingPresence
+	pendingPresence = map[string]string{}
+	return changes
+}
+
+// Sends the queued changes as presence_diff events on the shared channel. Changes of
+// users someone has blocked are sent on their own to everyone else, like their other events.
+func flushPresenceChanges(ctx context.Context) {
+	var shared []PresenceChange
+	for uid, status := range takePresenceChanges() {
+		change := PresenceChange{UserID: uid, Status: status}
+
+		recipients, err := broadcastRecipients(ctx, uid)
+		if err != nil {
+			log.Println("error sending presence change:", err)
+			continue
+		}
+		if recipients != nil {
+			notifyUsers(recipients, "presence_diff", map[string]any{"changes": []PresenceChange{change}})
+			continue
+		}
+		shared = append(shared, change)
+	}
+
+	for start := 0; start < len(shared); start += maxPresenceChanges {
+		batch := shared[start:min(start+maxPresenceChanges, len(shared))]
+		broadcast("presence_diff", map[string]any{"changes": batch})
+	}
+}
+
+// Sends queued presence changes every few seconds until the context is cancelled, so
+// clients can keep their online users list current without polling it.
+func StartPresenceFeed(ctx context.Context) {
+	ticker := time.NewTicker(presenceFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			flushPresenceChanges(ctx)
+		}
+	}
+}
@@ -1,29 +1,177 @@
 package routes
 
 import (
+	"chat-app-back/src/avatar"
 	"chat-app-back/src/config"
 	"chat-app-back/src/middlewares"
 	"chat-app-back/src/models"
 	"chat-app-back/src/util"
+	"context"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type ChangeUsername struct {
-	Username string `json:"username" binding:"required"`
+	Username string `json:"username" validate:"required"`
 }
 
+// ExpiresIn is in seconds. Statuses without it stay until they are changed or cleared.
 type ChangeStatus struct {
-	CustomStatus string `json:"custom_status" binding:"required"`
+	Emoji     string `json:"emoji" validate:"max=32"`
+	Text      string `json:"text" validate:"max=100"`
+	ExpiresIn int64  `json:"expires_in" validate:"omitempty,min=60,max=7776000"`
+}
+
+type ProfileLink struct {
+	Label string `json:"label" validate:"required,max=30"`
+	URL   string `json:"url" validate:"required,max=200,url,startswith=https://"`
+}
+
+// Fields left out are unchanged, and empty values clear them. Visibility only
+// changes the fields it lists.
+type UpdateProfile struct {
+	DisplayName *string           `json:"display_name" validate:"omitempty,max=50"`
+	Bio         *string           `json:"bio" validate:"omitempty,max=500"`
+	Pronouns    *string           `json:"pronouns" validate:"omitempty,max=40"`
+	Timezone    *string           `json:"timezone" validate:"omitempty,max=64"`
+	Links       *[]ProfileLink    `json:"links" validate:"omitempty,max=5,dive"`
+	Visibility  map[string]string `json:"visibility" validate:"omitempty,dive,keys,oneof=bio pronouns timezone banner links directory,endkeys,oneof=public contacts private"`
 }
 
 type UserProfileResponse struct {
-	ID             string  `json:"id"`
-	Username       string  `json:"username"`
-	CustomStatus   *string `json:"custom_status"`
-	ProfilePicture *string `json:"profile_picture"`
+	ID             string               `json:"id"`
+	Username       string               `json:"username"`
+	DisplayName    string               `json:"display_name,omitempty"`
+	CustomStatus   *models.CustomStatus `json:"custom_status"`
+	ProfilePicture *string              `json:"profile_picture"`
+	Bio            string               `json:"bio,omitempty"`
+	Pronouns       string               `json:"pronouns,omitempty"`
+	Timezone       string               `json:"timezone,omitempty"`
+	Banner         string               `json:"banner,omitempty"`
+	Links          []models.SocialLink  `json:"links,omitempty"`
+
+	// Only included in the user's own profile.
+	Visibility map[string]string `json:"visibility,omitempty"`
+}
+
+// The part of a profile everyone can see.
+func profileResponse(user models.User) UserProfileResponse {
+	return UserProfileResponse{
+		ID:             user.ID.Hex(),
+		Username:       user.Username,
+		DisplayName:    user.DisplayName,
+		CustomStatus:   user.CustomStatus.Active(time.Now()),
+		ProfilePicture: user.ProfilePicture,
+	}
+}
+
+// Builds the profile as the viewer is allowed to see it. Friends count as contacts.
+func profileResponseFor(ctx context.Context, user models.User, viewerID string) (UserProfileResponse, error) {
+	contact := false
+	if viewerID != user.ID.Hex() && hasContactsOnlyFields(user.Profile) {
+		var err error
+		contact, err = areFriends(ctx, viewerID, user.ID.Hex())
+		if err != nil {
+			return UserProfileResponse{}, err
+		}
+	}
+
+	return profileResponseForContact(user, viewerID, contact), nil
+}
+
+func hasContactsOnlyFields(profile *models.Profile) bool {
+	for _, field := range models.ProfileFields {
+		if profile.FieldVisibility(field) == models.VisibilityContacts {
+			return true
+		}
+	}
+	return false
+}
+
+// Builds the profile as a viewer who is or isn't one of the user's contacts sees it.
+// Users see all of their own profile, along with who each field is shown to.
+func profileResponseForContact(user models.User, viewerID string, contact bool) UserProfileResponse {
+	response := profileResponse(user)
+	profile := user.Profile
+	if profile == nil {
+		return response
+	}
+
+	self := viewerID == user.ID.Hex()
+	visible := func(field string) bool {
+		switch profile.FieldVisibility(field) {
+		case models.VisibilityPublic:
+			return true
+		case models.VisibilityContacts:
+			return self || contact
+		default:
+			return self
+		}
+	}
+
+	if visible(models.ProfileFieldBio) {
+		response.Bio = profile.Bio
+	}
+	if visible(models.ProfileFieldPronouns) {
+		response.Pronouns = profile.Pronouns
+	}
+	if visible(models.ProfileFieldTimezone) {
+		response.Timezone = profile.Timezone
+	}
+	if visible(models.ProfileFieldBanner) && profile.BannerVersion != "" {
+		response.Banner = avatar.BannerURL(user.ID.Hex(), profile.BannerVersion)
+	}
+	if visible(models.ProfileFieldLinks) {
+		response.Links = profile.Links
+	}
+
+	if self {
+		response.Visibility = make(map[string]string, len(models.ProfileFields))
+		for _, field := range models.ProfileFields {
+			response.Visibility[field] = profile.FieldVisibility(field)
+		}
+	}
+
+	return response
+}
+
+// Reports whether the text has no control characters, other than line breaks when
+// they are allowed.
+func validProfileText(text string, multiline bool) bool {
+	for _, r := range text {
+		if unicode.IsControl(r) && !(multiline && r == '\n') {
+			return false
+		}
+	}
+	return true
+}
+
+// Loads the user making the request.
+func fetchCurrentUser(c *gin.Context) (*models.User, error) {
+	db := config.MongoClient()
+
+	objectID, err := primitive.ObjectIDFromHex(util.GetUid(c))
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err = db.Database("Chat-App").Collection("users").FindOne(c, bson.M{"_id": objectID}).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
 }
 
 func HandleChangeUsername(c *gin.Context) {
@@ -43,26 +191,62 @@ func HandleChangeUsername(c *gin.Context) {
 		return
 	}
 
-	// Get the sender's uid and attempt to change the username.
-	uid := util.GetUid(c)
-	objectID, err := primitive.ObjectIDFromHex(uid)
+	user, err := fetchCurrentUser(c)
 	if err != nil {
-		c.JSON(400, gin.H{"status": "error", "message": "Invalid user ID"})
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to change username"})
 		return
 	}
+	uid := user.ID.Hex()
 
-	filter := bson.M{"_id": objectID}
-	update := bson.M{"$set": bson.M{"username": changeUsername.Username}}
+	username, usernameKey, err := util.PrepareUsername(c, changeUsername.Username, uid)
+	if err != nil {
+		util.RespondWithUsernameError(c, err, "Failed to change username")
+		return
+	}
 
-	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, filter, update)
+	// Changing only the case or the look of the same name is not a new name
+	now := time.Now()
+	renamed := usernameKey != util.UsernameKey(user.Username)
+	if renamed && user.UsernameChangedAt != nil {
+		nextChange := user.UsernameChangedAt.Time().Add(util.UsernameChangeCooldown)
+		if now.Before(nextChange) {
+			c.JSON(429, gin.H{"status": "error", "message": "Username was changed recently", "next_change_at": primitive.NewDateTimeFromTime(nextChange)})
+			return
+		}
+	}
+
+	set := bson.M{"username": username, "username_key": usernameKey, "username_search_key": util.UsernameSearchKey(username)}
+	if renamed {
+		set["username_changed_at"] = primitive.NewDateTimeFromTime(now)
+	}
+	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, bson.M{"_id": user.ID}, bson.M{"$set": set})
+	if mongo.IsDuplicateKeyError(err) {
+		util.RespondWithUsernameError(c, util.ErrUsernameTaken, "Failed to change username")
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"status": "error", "message": "Failed to change username"})
 		return
 	}
 
-	c.JSON(200, gin.H{"status": "success", "message": "Username changed successfully"})
+	// Keep the old name so links to it still resolve
+	if renamed && user.Username != "" {
+		_, err = db.Database("Chat-App").Collection("username_history").InsertOne(c, models.UsernameHistory{
+			ID:          primitive.NewObjectID(),
+			UserID:      uid,
+			Username:    user.Username,
+			UsernameKey: util.UsernameKey(user.Username),
+			ChangedAt:   primitive.NewDateTimeFromTime(now),
+		})
+		if err != nil {
+			log.Println("error recording previous username:", err)
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Username changed successfully", "username": username})
 }
 
+// Sets the user's emoji and text status, optionally clearing it after ExpiresIn seconds.
 func HandleChangeStatus(c *gin.Context) {
 	db := config.MongoClient()
 
@@ -80,6 +264,27 @@ func HandleChangeStatus(c *gin.Context) {
 		return
 	}
 
+	status := models.CustomStatus{
+		Emoji: strings.TrimSpace(changeStatus.Emoji),
+		Text:  strings.TrimSpace(changeStatus.Text),
+	}
+	if status.Emoji == "" && status.Text == "" {
+		c.JSON(400, gin.H{"status": "error", "message": "Status needs an emoji or text"})
+		return
+	}
+	if status.Emoji != "" && !validStatusEmoji(status.Emoji) {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid status emoji"})
+		return
+	}
+	if !validProfileText(status.Text, false) {
+		c.JSON(400, gin.H{"status": "error", "message": "Status can't contain control characters"})
+		return
+	}
+	if changeStatus.ExpiresIn > 0 {
+		expiresAt := primitive.NewDateTimeFromTime(time.Now().Add(time.Duration(changeStatus.ExpiresIn) * time.Second))
+		status.ExpiresAt = &expiresAt
+	}
+
 	// Get the sender's uid and attempt to change the status.
 	uid := util.GetUid(c)
 	objectID, err := primitive.ObjectIDFromHex(uid)
@@ -89,13 +294,170 @@ func HandleChangeStatus(c *gin.Context) {
 	}
 
 	filter := bson.M{"_id": objectID}
-	update := bson.M{"$set": bson.M{"custom_status": changeStatus.CustomStatus}}
+	update := bson.M{"$set": bson.M{"custom_status": status}}
 	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, filter, update)
 	if err != nil {
 		c.JSON(500, gin.H{"status": "error", "message": "Failed to change status"})
 		return
 	}
-	c.JSON(200, gin.H{"status": "success", "message": "Status changed successfully"})
+
+	broadcastCustomStatus(c, uid, &status)
+	c.JSON(200, gin.H{"status": "success", "message": "Status changed successfully", "custom_status": status})
+}
+
+func HandleClearStatus(c *gin.Context) {
+	db := config.MongoClient()
+
+	uid := util.GetUid(c)
+	objectID, err := primitive.ObjectIDFromHex(uid)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid user ID"})
+		return
+	}
+
+	filter := bson.M{"_id": objectID}
+	update := bson.M{"$unset": bson.M{"custom_status": ""}}
+	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, filter, update)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to clear status"})
+		return
+	}
+
+	broadcastCustomStatus(c, uid, nil)
+	c.JSON(200, gin.H{"status": "success", "message": "Status cleared successfully"})
+}
+
+// Orders visibilities from the most users allowed to see a field to the fewest.
+var visibilityRanks = map[string]int{models.VisibilityPublic: 0, models.VisibilityContacts: 1, models.VisibilityPrivate: 2}
+
+// Updates the display name, bio, pronouns, timezone and links, and who can see each of them.
+func HandleUpdateProfile(c *gin.Context) {
+	db := config.MongoClient()
+
+	var updateProfile UpdateProfile
+
+	// Validate json structure
+	err := c.BindJSON(&updateProfile)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(updateProfile)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(util.GetUid(c))
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid user ID"})
+		return
+	}
+
+	set := bson.M{}
+	unset := bson.M{}
+	setText := func(field string, value *string, multiline bool) bool {
+		if value == nil {
+			return true
+		}
+		text := strings.TrimSpace(*value)
+		if !validProfileText(text, multiline) {
+			return false
+		}
+		if text == "" {
+			unset["profile."+field] = ""
+		} else {
+			set["profile."+field] = text
+		}
+		return true
+	}
+	if displayName := updateProfile.DisplayName; displayName != nil {
+		name := strings.TrimSpace(*displayName)
+		if !validProfileText(name, false) {
+			c.JSON(400, gin.H{"status": "error", "message": "Profile fields can't contain control characters"})
+			return
+		}
+		if name == "" {
+			unset["display_name"] = ""
+			unset["display_name_terms"] = ""
+		} else {
+			set["display_name"] = name
+			set["display_name_terms"] = util.SearchTerms(name)
+		}
+	}
+	if !setText(models.ProfileFieldBio, updateProfile.Bio, true) || !setText(models.ProfileFieldPronouns, updateProfile.Pronouns, false) {
+		c.JSON(400, gin.H{"status": "error", "message": "Profile fields can't contain control characters"})
+		return
+	}
+	if timezone := updateProfile.Timezone; timezone != nil && *timezone != "" {
+		if _, err := time.LoadLocation(*timezone); err != nil || *timezone == "Local" {
+			c.JSON(400, gin.H{"status": "error", "message": "Unknown timezone"})
+			return
+		}
+	}
+	setText(models.ProfileFieldTimezone, updateProfile.Timezone, false)
+
+	if updateProfile.Links != nil {
+		links := []models.SocialLink{}
+		for _, link := range *updateProfile.Links {
+			label := strings.TrimSpace(link.Label)
+			if label == "" || !validProfileText(label, false) {
+				c.JSON(400, gin.H{"status": "error", "message": "Invalid link label"})
+				return
+			}
+			links = append(links, models.SocialLink{Label: label, URL: link.URL})
+		}
+		if len(links) == 0 {
+			unset["profile.links"] = ""
+		} else {
+			set["profile.links"] = links
+		}
+	}
+
+	// Public is the default, so it isn't stored
+	for field, visibility := range updateProfile.Visibility {
+		if visibility == models.VisibilityPublic {
+			unset["profile.visibility."+field] = ""
+		} else {
+			set["profile.visibility."+field] = visibility
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if len(update) == 0 {
+		c.JSON(400, gin.H{"status": "error", "message": "Nothing to update"})
+		return
+	}
+
+	var previous models.User
+	err = db.Database("Chat-App").Collection("users").FindOneAndUpdate(c, bson.M{"_id": objectID}, update).Decode(&previous)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to update profile"})
+		return
+	}
+	user, err := fetchCurrentUser(c)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to update profile"})
+		return
+	}
+
+	// Whoever can no longer see the banner may still have its URL
+	bannerVisibility := previous.Profile.FieldVisibility(models.ProfileFieldBanner)
+	if user.Profile != nil && user.Profile.BannerVersion != "" &&
+		visibilityRanks[user.Profile.FieldVisibility(models.ProfileFieldBanner)] > visibilityRanks[bannerVisibility] {
+		if err := rotateBanner(c, user); err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to update profile"})
+			return
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Profile updated successfully", "user_profile": profileResponseForContact(*user, user.ID.Hex(), false)})
 }
 
 func HandleGetUserProfile(c *gin.Context) {
@@ -118,18 +480,54 @@ func HandleGetUserProfile(c *gin.Context) {
 		return
 	}
 
-	// Create a UserProfileResponse object
-	userProfile := UserProfileResponse{
-		ID:             user.ID.Hex(),
-		Username:       user.Username,
-		CustomStatus:   user.CustomStatus,
-		ProfilePicture: user.ProfilePicture,
+	// Only include the fields the user shows to whoever is asking
+	userProfile, err := profileResponseFor(c, user, util.GetUid(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch user profile"})
+		return
 	}
 
 	// Return the user profile as a JSON response
 	c.JSON(200, gin.H{"status": "success", "user_profile": userProfile})
 }
 
+// Finds a user by their current username, or by a name they had before. A previous
+// name only resolves to its old owner while nobody else has taken it.
+func HandleGetUserByUsername(c *gin.Context) {
+	db := config.MongoClient()
+
+	key := util.UsernameKey(c.Param("username"))
+
+	var user models.User
+	previous := false
+	err := db.Database("Chat-App").Collection("users").FindOne(c, bson.M{"username_key": key}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		var history models.UsernameHistory
+		err = db.Database("Chat-App").Collection("username_history").FindOne(c, bson.M{"username_key": key},
+			options.FindOne().SetSort(bson.M{"changed_at": -1})).Decode(&history)
+		if err == nil {
+			var objectID primitive.ObjectID
+			objectID, err = primitive.ObjectIDFromHex(history.UserID)
+			if err == nil {
+				err = db.Database("Chat-App").Collection("users").FindOne(c, bson.M{"_id": objectID}).Decode(&user)
+				previous = true
+			}
+		}
+	}
+	if err != nil {
+		c.JSON(404, gin.H{"status": "error", "message": "User not found"})
+		return
+	}
+
+	userProfile, err := profileResponseFor(c, user, util.GetUid(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch user profile"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "user_profile": userProfile, "previous_username": previous})
+}
+
 func HandleGetOwnProfile(c *gin.Context) {
 	db := config.MongoClient()
 
@@ -151,56 +549,99 @@ func HandleGetOwnProfile(c *gin.Context) {
 	}
 
 	// Create a UserProfileResponse object
-	userProfile := UserProfileResponse{
-		ID:             user.ID.Hex(),
-		Username:       user.Username,
-		CustomStatus:   user.CustomStatus,
-		ProfilePicture: user.ProfilePicture,
-	}
+	userProfile := profileResponseForContact(user, uid, false)
 
 	// Return the user profile as a JSON response
 	c.JSON(200, gin.H{"status": "success", "user_profile": userProfile})
 }
 
+const maxOnlineUsers = 100
+
+// Lists the users with a status, online unless another is given, ordered by username.
+// Pass the last username of a page as after to get the next one. The counts cover every
+// page, and clients keep the list current with presence_diff events. channel_id only
+// checks that the user belongs to the channel: there is a single channel, and every
+// user is a member, so it doesn't narrow the list.
 func HandleGetOnlineUsers(c *gin.Context) {
 	db := config.MongoClient()
 
-	// Find all users with a status of "online"
-	filter := bson.M{"status": "online"}
-	cursor, err := db.Database("Chat-App").Collection("users").Find(c, filter)
+	status := c.DefaultQuery("status", models.StatusOnline)
+	if !models.ValidStatus(status) {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid status"})
+		return
+	}
+
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(400, gin.H{"status": "error", "message": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, maxOnlineUsers)
+	}
+
+	// Blocked users' presence is hidden, and the list can be narrowed to friends
+	uid := util.GetUid(c)
+	blocked, err := blockedIDs(c, uid)
 	if err != nil {
 		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch online users"})
 		return
 	}
-	defer cursor.Close(c)
-
-	// Create a slice of UserProfileResponse objects
-	var userProfiles []UserProfileResponse
-	for cursor.Next(c) {
-		var user models.User
-		err := cursor.Decode(&user)
+	idFilter := bson.M{"$nin": objectIDsFromHex(blocked)}
+	if c.Query("friends") == "true" {
+		ids, err := friendIDs(c, uid)
 		if err != nil {
 			c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch online users"})
 			return
 		}
+		idFilter["$in"] = objectIDsFromHex(ids)
+	}
+	filter := bson.M{"_id": idFilter, "disabled": bson.M{"$ne": true}}
 
-		userProfile := UserProfileResponse{
-			ID:             user.ID.Hex(),
-			Username:       user.Username,
-			CustomStatus:   user.CustomStatus,
-			ProfilePicture: user.ProfilePicture,
-		}
+	// A channel the user isn't a member of is refused. The default channel is the only
+	// one and has every user as a member, so there is nothing to filter by
+	if channelID := c.Query("channel_id"); channelID != "" && !slices.Contains(userChannelIDs(uid), channelID) {
+		c.JSON(403, gin.H{"status": "error", "message": "Not a member of this channel"})
+		return
+	}
 
-		userProfiles = append(userProfiles, userProfile)
+	counts, err := presenceCounts(c, filter)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch online users"})
+		return
 	}
 
-	if err := cursor.Err(); err != nil {
+	filter["status"] = status
+	if status == models.StatusOffline {
+		filter["status"] = bson.M{"$in": bson.A{nil, models.StatusOffline}}
+	}
+	if after := c.Query("after"); after != "" {
+		filter["username_key"] = bson.M{"$gt": after}
+	}
+
+	findOptions := options.Find().SetSort(bson.M{"username_key": 1}).SetLimit(int64(limit))
+	cursor, err := db.Database("Chat-App").Collection("users").Find(c, filter, findOptions)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch online users"})
+		return
+	}
+	var users []models.User
+	if err := cursor.All(c, &users); err != nil {
 		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch online users"})
 		return
 	}
 
-	// Return the user profiles as a JSON response
-	c.JSON(200, gin.H{"status": "success", "online_users": userProfiles})
+	userProfiles := make([]UserProfileResponse, 0, len(users))
+	for _, user := range users {
+		userProfiles = append(userProfiles, profileResponse(user))
+	}
+
+	response := gin.H{"status": "success", "online_users": userProfiles, "counts": counts}
+	if len(users) == limit {
+		response["next"] = users[len(users)-1].UsernameKey
+	}
+	c.JSON(200, response)
 }
 
 func ProfileRoutes(route *gin.RouterGroup) {
@@ -208,8 +649,11 @@ func ProfileRoutes(route *gin.RouterGroup) {
 	{
 		profileGroup.POST("change_username", middlewares.AuthenticateAccessToken(), HandleChangeUsername)
 		profileGroup.POST("change_custom_status", middlewares.AuthenticateAccessToken(), HandleChangeStatus)
-		profileGroup.GET("user_profile/:user_id", middlewares.AuthenticateAccessToken(), HandleGetUserProfile)
-		profileGroup.GET("user_profile", middlewares.AuthenticateAccessToken(), HandleGetOwnProfile)
+		profileGroup.POST("clear_custom_status", middlewares.AuthenticateAccessToken(), HandleClearStatus)
+		profileGroup.POST("update_profile", middlewares.AuthenticateAccessToken(), HandleUpdateProfile)
+		profileGroup.GET("user_profile/:user_id", middlewares.AuthenticateAccessToken(models.ScopeProfileRead), HandleGetUserProfile)
+		profileGroup.GET("user_profile", middlewares.AuthenticateAccessToken(models.ScopeProfileRead), HandleGetOwnProfile)
+		profileGroup.GET("user_by_username/:username", middlewares.AuthenticateAccessToken(models.ScopeProfileRead), HandleGetUserByUsername)
 		profileGroup.GET("get_online_users", middlewares.AuthenticateAccessToken(), HandleGetOnlineUsers)
 	}
 }
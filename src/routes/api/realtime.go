@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"context"
+	"io"
+	"log"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Private channel for events only one user should receive. Clients subscribe to it
+// next to the shared channel, since the events of users someone has blocked arrive here.
+func userChannel(uid string) string {
+	return "private-user-" + uid
+}
+
+// Pusher accepts at most this many channels per trigger.
+const maxTriggerChannels = 100
+
+// Sends an event to each of the users on their private channel.
+func notifyUsers(uids []string, event string, data any) {
+	channels := make([]string, 0, len(uids))
+	for _, uid := range uids {
+		channels = append(channels, userChannel(uid))
+	}
+
+	for start := 0; start < len(channels); start += maxTriggerChannels {
+		batch := channels[start:min(start+maxTriggerChannels, len(channels))]
+		if err := config.PusherInit().TriggerMulti(batch, event, data); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+// Sends an event to everyone on the shared channel.
+func broadcast(event string, data any) {
+	if err := config.PusherInit().Trigger("super-chat-channel", event, data); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// Returns the IDs of the members of the default channel, the only channel, other than
+// the excluded users. Every user is a member.
+func channelMemberIDs(ctx context.Context, excluded []string) ([]string, error) {
+	db := config.MongoClient()
+
+	filter := bson.M{"_id": bson.M{"$nin": objectIDsFromHex(excluded)}}
+	cursor, err := db.Database("Chat-App").Collection("users").Find(ctx, filter,
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID.Hex())
+	}
+	return ids, nil
+}
+
+// Returns who should receive the user's messages, status and presence. Users who
+// blocked them must not, so when there are any this lists every other member, to be
+// reached on their private channels. Returns nil when the shared channel can be used.
+func broadcastRecipients(ctx context.Context, uid string) ([]string, error) {
+	blockers, err := blockerIDs(ctx, uid, models.BlockKindBlock)
+	if err != nil || len(blockers) == 0 {
+		return nil, err
+	}
+
+	return channelMemberIDs(ctx, blockers)
+}
+
+// Sends an event to the recipients from broadcastRecipients.
+func broadcastTo(recipients []string, event string, data any) {
+	if recipients != nil {
+		notifyUsers(recipients, event, data)
+		return
+	}
+
+	broadcast(event, data)
+}
+
+// Authorizes a Pusher connection to subscribe to the user's own private channel.
+func HandlePusherAuth(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 4096))
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	params, err := url.ParseQuery(string(body))
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	if params.Get("channel_name") != userChannel(util.GetUid(c)) {
+		c.JSON(403, gin.H{"status": "error", "message": "Channel not allowed"})
+		return
+	}
+
+	response, err := config.PusherInit().AuthorizePrivateChannel(body)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	c.Data(200, "application/json", response)
+}
+
+func RealtimeRoutes(route *gin.RouterGroup) {
+	realtimeGroup := route.Group("/")
+	{
+		realtimeGroup.POST("pusher_auth", middlewares.AuthenticateAccessToken(), HandlePusherAuth)
+	}
+}
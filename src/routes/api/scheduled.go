@@ -0,0 +1,367 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"context"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ScheduleMessage struct {
+	Content   string `json:"content" validate:"required"`
+	ChannelId string `json:"channel_id"`
+	SendAt    string `json:"send_at" validate:"required"`
+}
+
+type EditScheduledMessage struct {
+	Content *string `json:"content"`
+	SendAt  *string `json:"send_at"`
+}
+
+type ScheduledMessageResponse struct {
+	ID        string             `json:"id"`
+	ChannelID string             `json:"channel_id"`
+	Content   string             `json:"content"`
+	SendAt    primitive.DateTime `json:"send_at"`
+	Status    string             `json:"status"`
+	CreatedAt primitive.DateTime `json:"created_at"`
+	UpdatedAt primitive.DateTime `json:"updated_at"`
+}
+
+const (
+	scheduledPending   = "pending"
+	scheduledSending   = "sending"
+	scheduledSent      = "sent"
+	scheduledCancelled = "cancelled"
+	scheduledFailed    = "failed"
+)
+
+const (
+	schedulerInterval    = 5 * time.Second
+	schedulerLease       = time.Minute
+	maxScheduledAttempts = 5
+)
+
+// Parses a send_at value and checks that it is in the future.
+func parseSendAt(value string) (time.Time, bool) {
+	sendAt, err := time.Parse(time.RFC3339, value)
+	if err != nil || !sendAt.After(time.Now()) {
+		return time.Time{}, false
+	}
+
+	return sendAt, true
+}
+
+func toScheduledMessageResponse(message models.ScheduledMessage) ScheduledMessageResponse {
+	return ScheduledMessageResponse{
+		ID:        message.ID.Hex(),
+		ChannelID: message.ChannelID,
+		Content:   message.Content,
+		SendAt:    message.SendAt,
+		Status:    message.Status,
+		CreatedAt: message.CreatedAt,
+		UpdatedAt: message.UpdatedAt,
+	}
+}
+
+func HandleScheduleMessage(c *gin.Context) {
+	db := config.MongoClient()
+
+	var scheduleMessage ScheduleMessage
+
+	// Validate json structure
+	err := c.BindJSON(&scheduleMessage)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(scheduleMessage)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	sendAt, ok := parseSendAt(scheduleMessage.SendAt)
+	if !ok {
+		c.JSON(400, gin.H{"status": "error", "message": "send_at must be a future RFC 3339 date"})
+		return
+	}
+
+	uid := util.GetUid(c)
+	channelID, ok := resolveChannelID(uid, scheduleMessage.ChannelId)
+	if !ok {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid channel ID"})
+		return
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	message := models.ScheduledMessage{
+		ID:        primitive.NewObjectID(),
+		SenderID:  uid,
+		ChannelID: channelID,
+		Content:   scheduleMessage.Content,
+		SendAt:    primitive.NewDateTimeFromTime(sendAt),
+		Status:    scheduledPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	_, err = db.Database("Chat-App").Collection("scheduled_messages").InsertOne(c, message)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to schedule message"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Message scheduled successfully", "scheduled_message": toScheduledMessageResponse(message)})
+}
+
+func HandleGetScheduledMessages(c *gin.Context) {
+	db := config.MongoClient()
+
+	uid := util.GetUid(c)
+	filter := bson.M{"sender_id": uid, "status": bson.M{"$in": bson.A{scheduledPending, scheduledSending}}}
+	cursor, err := db.Database("Chat-App").Collection("scheduled_messages").Find(c, filter, options.Find().SetSort(bson.M{"send_at": 1}))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch scheduled messages"})
+		return
+	}
+	defer cursor.Close(c)
+
+	scheduledMessages := []ScheduledMessageResponse{}
+	for cursor.Next(c) {
+		var message models.ScheduledMessage
+		if err := cursor.Decode(&message); err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch scheduled messages"})
+			return
+		}
+
+		scheduledMessages = append(scheduledMessages, toScheduledMessageResponse(message))
+	}
+
+	if err := cursor.Err(); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch scheduled messages"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "scheduled_messages": scheduledMessages})
+}
+
+func HandleEditScheduledMessage(c *gin.Context) {
+	db := config.MongoClient()
+
+	objectID, err := primitive.ObjectIDFromHex(c.Param("message_id"))
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid message ID"})
+		return
+	}
+
+	var editMessage EditScheduledMessage
+	err = c.BindJSON(&editMessage)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	set := bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())}
+	if editMessage.Content != nil {
+		if *editMessage.Content == "" {
+			c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+			return
+		}
+		set["content"] = *editMessage.Content
+	}
+	if editMessage.SendAt != nil {
+		sendAt, ok := parseSendAt(*editMessage.SendAt)
+		if !ok {
+			c.JSON(400, gin.H{"status": "error", "message": "send_at must be a future RFC 3339 date"})
+			return
+		}
+		set["send_at"] = primitive.NewDateTimeFromTime(sendAt)
+	}
+
+	// Only pending messages can be edited, so a message the scheduler already claimed is left alone
+	var updated models.ScheduledMessage
+	filter := bson.M{"_id": objectID, "sender_id": util.GetUid(c), "status": scheduledPending}
+	err = db.Database("Chat-App").Collection("scheduled_messages").FindOneAndUpdate(c, filter, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(404, gin.H{"status": "error", "message": "Scheduled message not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to edit scheduled message"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Scheduled message updated successfully", "scheduled_message": toScheduledMessageResponse(updated)})
+}
+
+func HandleCancelScheduledMessage(c *gin.Context) {
+	db := config.MongoClient()
+
+	objectID, err := primitive.ObjectIDFromHex(c.Param("message_id"))
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid message ID"})
+		return
+	}
+
+	filter := bson.M{"_id": objectID, "sender_id": util.GetUid(c), "status": scheduledPending}
+	update := bson.M{"$set": bson.M{"status": scheduledCancelled, "updated_at": primitive.NewDateTimeFromTime(time.Now())}}
+	result, err := db.Database("Chat-App").Collection("scheduled_messages").UpdateOne(c, filter, update)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to cancel scheduled message"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(404, gin.H{"status": "error", "message": "Scheduled message not found"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Scheduled message cancelled successfully"})
+}
+
+// Claims the next due message. The claim is a lease, so a message held by an instance
+// that died is picked up again once the lease runs out.
+func claimScheduledMessage(ctx context.Context) (*models.ScheduledMessage, error) {
+	db := config.MongoClient()
+
+	now := time.Now()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": scheduledPending, "send_at": bson.M{"$lte": primitive.NewDateTimeFromTime(now)}},
+		bson.M{"status": scheduledSending, "locked_until": bson.M{"$lte": primitive.NewDateTimeFromTime(now)}},
+	}}
+	update := bson.M{
+		"$set": bson.M{"status": scheduledSending, "locked_until": primitive.NewDateTimeFromTime(now.Add(schedulerLease))},
+		"$inc": bson.M{"attempts": 1},
+	}
+
+	var message models.ScheduledMessage
+	err := db.Database("Chat-App").Collection("scheduled_messages").FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetSort(bson.M{"send_at": 1}).SetReturnDocument(options.After)).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+func finishScheduledMessage(ctx context.Context, id primitive.ObjectID, status string) {
+	db := config.MongoClient()
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	set := bson.M{"status": status, "updated_at": now}
+	if status == scheduledSent {
+		set["sent_at"] = now
+	}
+
+	_, err := db.Database("Chat-App").Collection("scheduled_messages").UpdateOne(ctx,
+		bson.M{"_id": id, "status": scheduledSending},
+		bson.M{"$set": set, "$unset": bson.M{"locked_until": ""}})
+	if err != nil {
+		log.Println("error updating scheduled message:", err)
+	}
+}
+
+// Marks a message whose attempt failed as failed once it has used up its attempts.
+// Otherwise it is retried when its lease runs out.
+func abandonFailedAttempt(ctx context.Context, scheduled models.ScheduledMessage) {
+	if scheduled.Attempts >= maxScheduledAttempts {
+		finishScheduledMessage(ctx, scheduled.ID, scheduledFailed)
+	}
+}
+
+// Posts a claimed message. The posted message reuses the scheduled message id, so if a
+// previous attempt already inserted it the duplicate key error marks it as sent instead
+// of posting it twice.
+func postScheduledMessage(ctx context.Context, scheduled models.ScheduledMessage) {
+	db := config.MongoClient()
+
+	objectID, err := primitive.ObjectIDFromHex(scheduled.SenderID)
+	if err != nil {
+		finishScheduledMessage(ctx, scheduled.ID, scheduledFailed)
+		return
+	}
+
+	var user models.User
+	err = db.Database("Chat-App").Collection("users").FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		finishScheduledMessage(ctx, scheduled.ID, scheduledFailed)
+		return
+	}
+	if err != nil {
+		log.Println("error fetching scheduled message sender:", err)
+		abandonFailedAttempt(ctx, scheduled)
+		return
+	}
+	if user.Disabled {
+		finishScheduledMessage(ctx, scheduled.ID, scheduledFailed)
+		return
+	}
+
+	message := models.Message{
+		ID:        scheduled.ID,
+		SenderID:  scheduled.SenderID,
+		ChannelID: scheduled.ChannelID,
+		Type:      models.MessageTypeText,
+		Content:   scheduled.Content,
+		Mentions:  extractMentions(ctx, scheduled.Content),
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	}
+
+	err = sendMessage(ctx, user, message)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		log.Println("error posting scheduled message:", err)
+		abandonFailedAttempt(ctx, scheduled)
+		return
+	}
+
+	finishScheduledMessage(ctx, scheduled.ID, scheduledSent)
+}
+
+// Polls for due scheduled messages and posts them until the context is cancelled.
+func StartMessageScheduler(ctx context.Context) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			message, err := claimScheduledMessage(ctx)
+			if err != nil {
+				log.Println("error claiming scheduled message:", err)
+				break
+			}
+			if message == nil {
+				break
+			}
+
+			postScheduledMessage(ctx, *message)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func ScheduledMessageRoutes(route *gin.RouterGroup) {
+	scheduledGroup := route.Group("/")
+	{
+		scheduledGroup.POST("schedule_message", middlewares.AuthenticateAccessToken(), HandleScheduleMessage)
+		scheduledGroup.GET("scheduled_messages", middlewares.AuthenticateAccessToken(), HandleGetScheduledMessages)
+		scheduledGroup.POST("edit_scheduled_message/:message_id", middlewares.AuthenticateAccessToken(), HandleEditScheduledMessage)
+		scheduledGroup.POST("cancel_scheduled_message/:message_id", middlewares.AuthenticateAccessToken(), HandleCancelScheduledMessage)
+	}
+}
@@ -0,0 +1,238 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"html"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type SearchResult struct {
+	MessageContent
+	Score     float64 `json:"score"`
+	Highlight string  `json:"highlight"`
+}
+
+type searchedMessage struct {
+	models.Message `bson:",inline"`
+	Score          float64       `bson:"score"`
+	User           []models.User `bson:"user"`
+}
+
+const maxSearchResults = 50
+
+// Splits a text search query into the terms used for highlighting, skipping negated terms.
+func searchTerms(query string) []string {
+	var terms []string
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		if strings.HasPrefix(field, "-") {
+			continue
+		}
+
+		term := strings.TrimFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+
+	return terms
+}
+
+// Escapes the content and wraps every word that starts with one of the terms in <mark> tags.
+// Prefix matching approximates the stemming done by the text index.
+func highlightContent(content string, terms []string) string {
+	var builder strings.Builder
+	runes := []rune(content)
+
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsNumber(runes[i]) {
+			builder.WriteString(html.EscapeString(string(runes[i])))
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsNumber(runes[j])) {
+			j++
+		}
+
+		word := string(runes[i:j])
+		lower := strings.ToLower(word)
+		matched := slices.ContainsFunc(terms, func(term string) bool {
+			return strings.HasPrefix(lower, term) || (len(lower) >= 3 && strings.HasPrefix(term, lower))
+		})
+
+		if matched {
+			builder.WriteString("<mark>" + word + "</mark>")
+		} else {
+			builder.WriteString(word)
+		}
+		i = j
+	}
+
+	return builder.String()
+}
+
+// Parses an optional RFC 3339 date query parameter.
+func parseDateParam(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, false
+	}
+
+	return &date, true
+}
+
+func HandleSearchMessages(c *gin.Context) {
+	db := config.MongoClient()
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(400, gin.H{"status": "error", "message": "Search query required"})
+		return
+	}
+
+	uid := util.GetUid(c)
+
+	// Only search the channels the user belongs to
+	channelIDs := userChannelIDs(uid)
+	if channelID := c.Query("channel_id"); channelID != "" {
+		if !slices.Contains(channelIDs, channelID) {
+			c.JSON(403, gin.H{"status": "error", "message": "Not a member of this channel"})
+			return
+		}
+		channelIDs = []string{channelID}
+	}
+
+	filter := bson.M{"$text": bson.M{"$search": query}}
+	for key, value := range channelFilter(channelIDs) {
+		filter[key] = value
+	}
+	for key, value := range notExpiredFilter() {
+		filter[key] = value
+	}
+	notBlocked, err := notBlockedFilter(c, uid)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to search messages"})
+		return
+	}
+	for key, value := range notBlocked {
+		filter[key] = value
+	}
+
+	if senderID := c.Query("sender_id"); senderID != "" {
+		filter["sender_id"] = senderID
+	}
+
+	from, ok := parseDateParam(c, "from")
+	if !ok {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid from date"})
+		return
+	}
+	to, ok := parseDateParam(c, "to")
+	if !ok {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid to date"})
+		return
+	}
+	if from != nil || to != nil {
+		createdAt := bson.M{}
+		if from != nil {
+			createdAt["$gte"] = primitive.NewDateTimeFromTime(*from)
+		}
+		if to != nil {
+			createdAt["$lte"] = primitive.NewDateTimeFromTime(*to)
+		}
+		filter["created_at"] = createdAt
+	}
+
+	if c.Query("has_attachment") == "true" {
+		filter["attachments.0"] = bson.M{"$exists": true}
+	}
+	if c.Query("mentions_me") == "true" {
+		filter["mentions"] = uid
+	}
+
+	limit := maxSearchResults
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(400, gin.H{"status": "error", "message": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, maxSearchResults)
+	}
+
+	// Rank by text score, newest first on ties
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: filter}},
+		bson.D{{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "created_at", Value: -1}}}},
+		bson.D{{Key: "$limit", Value: limit}},
+	}
+	pipeline = append(pipeline, senderLookupStages()...)
+
+	cursor, err := db.Database("Chat-App").Collection("messages").Aggregate(c, pipeline)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to search messages"})
+		return
+	}
+	defer cursor.Close(c)
+
+	terms := searchTerms(query)
+	results := []SearchResult{}
+	for cursor.Next(c) {
+		var message searchedMessage
+		if err := cursor.Decode(&message); err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to search messages"})
+			return
+		}
+
+		result := SearchResult{
+			MessageContent: MessageContent{
+				ID:        message.ID.Hex(),
+				SenderID:  message.SenderID,
+				CreatedAt: message.CreatedAt,
+				Type:      messageType(message.Message),
+				Content:   message.Content,
+				Poll:      toPollResponse(message.Poll, uid),
+				User:      messageUserFromLookup(message.User),
+			},
+			Score:     message.Score,
+			Highlight: highlightContent(message.Content, terms),
+		}
+
+		results = append(results, result)
+	}
+
+	if err := cursor.Err(); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to search messages"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "results": results})
+}
+
+func SearchRoutes(route *gin.RouterGroup) {
+	searchGroup := route.Group("/")
+	{
+		searchGroup.GET("search_messages", middlewares.AuthenticateAccessToken(), HandleSearchMessages)
+	}
+}
@@ -0,0 +1,141 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RevokeSession struct {
+	SessionID string `json:"session_id" validate:"required"`
+}
+
+type SessionResponse struct {
+	ID         string             `json:"id"`
+	DeviceName string             `json:"device_name"`
+	ClientID   string             `json:"client_id,omitempty"`
+	IPAddress  string             `json:"ip_address"`
+	UserAgent  string             `json:"user_agent"`
+	CreatedAt  primitive.DateTime `json:"created_at"`
+	LastUsedAt primitive.DateTime `json:"last_used_at"`
+	Current    bool               `json:"current"`
+}
+
+func HandleGetSessions(c *gin.Context) {
+	db := config.MongoClient()
+
+	uid := util.GetUid(c)
+	currentSessionID := util.GetSessionID(c)
+
+	filter := bson.M{"user_id": uid, "rotated_at": bson.M{"$exists": false}, "expires_at": bson.M{"$gt": primitive.NewDateTimeFromTime(time.Now())}}
+	cursor, err := db.Database("Chat-App").Collection("refresh_tokens").Find(c, filter, options.Find().SetSort(bson.M{"last_used_at": -1}))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch sessions"})
+		return
+	}
+	defer cursor.Close(c)
+
+	sessions := []SessionResponse{}
+	for cursor.Next(c) {
+		var session models.RefreshToken
+		if err := cursor.Decode(&session); err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch sessions"})
+			return
+		}
+
+		sessions = append(sessions, SessionResponse{
+			ID:         session.FamilyID.Hex(),
+			DeviceName: session.DeviceName,
+			ClientID:   session.ClientID,
+			IPAddress:  session.IPAddress,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			Current:    session.FamilyID.Hex() == currentSessionID,
+		})
+	}
+
+	if err := cursor.Err(); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch sessions"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "sessions": sessions})
+}
+
+func HandleRevokeSession(c *gin.Context) {
+	db := config.MongoClient()
+
+	var revokeSession RevokeSession
+	err := c.BindJSON(&revokeSession)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(revokeSession)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(revokeSession.SessionID)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid session ID"})
+		return
+	}
+
+	// Deleting the token family also invalidates its access tokens
+	result, err := db.Database("Chat-App").Collection("refresh_tokens").DeleteMany(c, bson.M{"family_id": sessionID, "user_id": util.GetUid(c)})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to revoke session"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(404, gin.H{"status": "error", "message": "Session not found"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Session revoked successfully"})
+}
+
+func HandleRevokeOtherSessions(c *gin.Context) {
+	db := config.MongoClient()
+
+	sessionID, err := primitive.ObjectIDFromHex(util.GetSessionID(c))
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid session ID"})
+		return
+	}
+
+	// A session keeps a row for each rotated token, so sessions are counted by family
+	filter := bson.M{"user_id": util.GetUid(c), "family_id": bson.M{"$ne": sessionID}}
+	families, err := db.Database("Chat-App").Collection("refresh_tokens").Distinct(c, "family_id", filter)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to revoke sessions"})
+		return
+	}
+	filter["family_id"] = bson.M{"$in": families}
+	_, err = db.Database("Chat-App").Collection("refresh_tokens").DeleteMany(c, filter)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Other sessions revoked successfully", "revoked": len(families)})
+}
+
+func SessionRoutes(route *gin.RouterGroup) {
+	sessionGroup := route.Group("/")
+	{
+		sessionGroup.GET("sessions", middlewares.AuthenticateAccessToken(), HandleGetSessions)
+		sessionGroup.POST("revoke_session", middlewares.AuthenticateAccessToken(), HandleRevokeSession)
+		sessionGroup.POST("revoke_other_sessions", middlewares.AuthenticateAccessToken(), HandleRevokeOtherSessions)
+	}
+}
@@ -0,0 +1,214 @@
+package routes
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	"chat-app-back/src/util"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type EnableTwoFactor struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type SecondFactorCode struct {
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func totpIssuer() string {
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		return "Chat-App"
+	}
+
+	return issuer
+}
+
+func HandleSetupTwoFactor(c *gin.Context) {
+	db := config.MongoClient()
+
+	user, err := fetchCurrentUser(c)
+	if err != nil {
+		c.JSON(404, gin.H{"status": "error", "message": "User not found"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(409, gin.H{"status": "error", "message": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	// The secret only becomes active once a code generated from it is confirmed
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to set up two-factor authentication"})
+		return
+	}
+	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"totp_pending_secret": secret}})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to set up two-factor authentication"})
+		return
+	}
+
+	account := user.Email
+	if account == "" {
+		account = user.Username
+	}
+
+	c.JSON(200, gin.H{"status": "success", "secret": secret, "provisioning_uri": util.TOTPProvisioningURI(secret, totpIssuer(), account)})
+}
+
+func HandleEnableTwoFactor(c *gin.Context) {
+	db := config.MongoClient()
+
+	var enable EnableTwoFactor
+	err := c.BindJSON(&enable)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(enable)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	user, err := fetchCurrentUser(c)
+	if err != nil {
+		c.JSON(404, gin.H{"status": "error", "message": "User not found"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(409, gin.H{"status": "error", "message": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TOTPPendingSecret == "" {
+		c.JSON(400, gin.H{"status": "error", "message": "Two-factor authentication has not been set up"})
+		return
+	}
+
+	step, ok := util.ValidateTOTP(user.TOTPPendingSecret, enable.Code, time.Now())
+	if !ok {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid code"})
+		return
+	}
+
+	codes, hashes, err := util.GenerateRecoveryCodes()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	filter := bson.M{"_id": user.ID, "totp_pending_secret": user.TOTPPendingSecret}
+	update := bson.M{
+		"$set": bson.M{
+			"totp_enabled":   true,
+			"totp_secret":    user.TOTPPendingSecret,
+			"totp_last_step": step,
+			"recovery_codes": hashes,
+		},
+		"$unset": bson.M{"totp_pending_secret": ""},
+	}
+	result, err := db.Database("Chat-App").Collection("users").UpdateOne(c, filter, update)
+	if err != nil || result.ModifiedCount == 0 {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Two-factor authentication enabled", "recovery_codes": codes})
+}
+
+func HandleDisableTwoFactor(c *gin.Context) {
+	db := config.MongoClient()
+
+	var secondFactor SecondFactorCode
+	err := c.BindJSON(&secondFactor)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(secondFactor)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	user, err := fetchCurrentUser(c)
+	if err != nil {
+		c.JSON(404, gin.H{"status": "error", "message": "User not found"})
+		return
+	}
+	if !util.VerifySecondFactor(c, *user, secondFactor.Code, secondFactor.RecoveryCode) {
+		c.JSON(401, gin.H{"status": "error", "message": "Invalid code"})
+		return
+	}
+
+	update := bson.M{"$unset": bson.M{
+		"totp_enabled":        "",
+		"totp_secret":         "",
+		"totp_pending_secret": "",
+		"totp_last_step":      "",
+		"recovery_codes":      "",
+	}}
+	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, bson.M{"_id": user.ID}, update)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Two-factor authentication disabled"})
+}
+
+func HandleRegenerateRecoveryCodes(c *gin.Context) {
+	db := config.MongoClient()
+
+	var secondFactor SecondFactorCode
+	err := c.BindJSON(&secondFactor)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(secondFactor)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	user, err := fetchCurrentUser(c)
+	if err != nil {
+		c.JSON(404, gin.H{"status": "error", "message": "User not found"})
+		return
+	}
+	if !util.VerifySecondFactor(c, *user, secondFactor.Code, secondFactor.RecoveryCode) {
+		c.JSON(401, gin.H{"status": "error", "message": "Invalid code"})
+		return
+	}
+
+	codes, hashes, err := util.GenerateRecoveryCodes()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to generate recovery codes"})
+		return
+	}
+
+	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"recovery_codes": hashes}})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "recovery_codes": codes})
+}
+
+func TwoFactorRoutes(route *gin.RouterGroup) {
+	twoFactorGroup := route.Group("/2fa")
+	{
+		twoFactorGroup.POST("setup", middlewares.AuthenticateAccessToken(), HandleSetupTwoFactor)
+		twoFactorGroup.POST("enable", middlewares.AuthenticateAccessToken(), HandleEnableTwoFactor)
+		twoFactorGroup.POST("disable", middlewares.AuthenticateAccessToken(), HandleDisableTwoFactor)
+		twoFactorGroup.POST("recovery_codes", middlewares.AuthenticateAccessToken(), HandleRegenerateRecoveryCodes)
+	}
+}
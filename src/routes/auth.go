@@ -1,20 +1,22 @@
 package routes
 
 import (
+	"chat-app-back/src/avatar"
 	config "chat-app-back/src/config"
+	"chat-app-back/src/identity"
 	models "chat-app-back/src/models"
 	"chat-app-back/src/util"
 	"context"
 	"fmt"
+	"log"
 	"os"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type GoogleToken struct {
@@ -30,39 +32,40 @@ type RefreshTokenBody struct {
 	Token string `json:"refresh_token" validate:"required"`
 }
 
+type FakeIdentityTokenBody struct {
+	Email   string `json:"email" validate:"required,email"`
+	Subject string `json:"subject"`
+	Picture string `json:"picture"`
+}
+
 var validate = validator.New()
 
 // Handles authenticating valid refresh tokens
 func authenticateRefreshToken(refreshToken string) (string, error) {
-	// Get token secret from env file
-	tokenSecret := os.Getenv("REFRESH_TOKEN_KEY")
-
-	// Initialize instance of Claims
-	claims := &jwt.MapClaims{}
-
-	// Attempt to parse the token
-	parsedToken, err := jwt.ParseWithClaims(refreshToken, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
-
-	// Check if token was parsed
-	if err != nil || !parsedToken.Valid {
+	claims, err := util.ParseToken(refreshToken, util.TokenTypeRefresh)
+	if err != nil {
 		return "", err
 	}
 
-	return (*claims)["uid"].(string), nil
+	// Refresh tokens of third-party apps are refreshed through the OAuth token endpoint
+	if claims.Scoped() {
+		return "", util.ErrInvalidTokenClaims
+	}
+
+	return claims.UID, nil
 }
 
 // Handles generating access and refresh tokens
-func generateTokens(uid string) (string, string) {
+func generateTokens(user models.User, sessionID string) (string, string) {
+	uid := user.ID.Hex()
 	var expirationTime int64 = 60 * 60 * 24 // 24 hours in seconds
-	accessToken, err := util.GenerateToken(uid, expirationTime, false)
+	accessToken, err := util.GenerateToken(uid, sessionID, user.Role, nil, expirationTime, false)
 	if err != nil {
 		return "", ""
 	}
 
 	// Add 7 days to expiration time for refresh tokens
-	refreshToken, err := util.GenerateToken(uid, expirationTime*7, true)
+	refreshToken, err := util.GenerateToken(uid, sessionID, user.Role, nil, expirationTime*7, true)
 	if err != nil {
 		return "", ""
 	}
@@ -70,7 +73,43 @@ func generateTokens(uid string) (string, string) {
 	return accessToken, refreshToken
 }
 
-func insertNewRefreshToken(userId string, refreshToken string, expirationTime int64) bool {
+// Issues a new token pair for the user, stores the refresh token and responds with both.
+func respondWithNewSession(c *gin.Context, user models.User, message string) {
+	if user.Disabled {
+		c.JSON(403, gin.H{"status": "error", "message": "Account disabled"})
+		return
+	}
+
+	uid := user.ID.Hex()
+	familyID := primitive.NewObjectID()
+	accessToken, refreshToken := generateTokens(user, familyID.Hex())
+	if accessToken == "" || refreshToken == "" {
+		c.JSON(500, gin.H{"status": "error", "message": "Error generating tokens"})
+		return
+	}
+
+	device := util.DeviceFromRequest(c)
+	inserted := insertNewRefreshToken(models.RefreshToken{
+		FamilyID:   familyID,
+		UserID:     uid,
+		DeviceName: device.Name,
+		IPAddress:  device.IPAddress,
+		UserAgent:  device.UserAgent,
+		CreatedAt:  primitive.NewDateTimeFromTime(time.Now()),
+	}, refreshToken, 60*60*24*7)
+	if !inserted {
+		c.JSON(500, gin.H{"status": "error", "message": "Error updating refresh token"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": message, "access_token": accessToken, "refresh_token": refreshToken})
+
+	// Set offline status after 15 minutes
+	util.SetOfflineAfterDuration(uid, 15*time.Minute, c)
+}
+
+// Saves a refresh token to its family. The session fields are taken from the given row.
+func insertNewRefreshToken(session models.RefreshToken, refreshToken string, expirationTime int64) bool {
 	db := config.MongoClient()
 
 	// Save refresh token to database
@@ -78,40 +117,122 @@ func insertNewRefreshToken(userId string, refreshToken string, expirationTime in
 	defer cancel()
 
 	_, err := db.Database("Chat-App").Collection("refresh_tokens").InsertOne(c, models.RefreshToken{
-		ID:           primitive.NewObjectID(),
-		UserID:       userId,
-		RefreshToken: refreshToken,
-		CreatedAt:    primitive.NewDateTimeFromTime(time.Now()),
-		ExpiresAt:    primitive.NewDateTimeFromTime(time.Now().Add(time.Second * time.Duration(expirationTime)))})
+		ID:         primitive.NewObjectID(),
+		FamilyID:   session.FamilyID,
+		UserID:     session.UserID,
+		TokenHash:  util.HashRefreshToken(refreshToken),
+		ClientID:   session.ClientID,
+		Scopes:     session.Scopes,
+		DeviceName: session.DeviceName,
+		IPAddress:  session.IPAddress,
+		UserAgent:  session.UserAgent,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: primitive.NewDateTimeFromTime(time.Now()),
+		ExpiresAt:  primitive.NewDateTimeFromTime(time.Now().Add(time.Second * time.Duration(expirationTime)))})
 
 	return err == nil
 }
 
-func updateRefreshToken(oldRefreshToken string, newRefreshToken string, userId string, expirationTime int64) bool {
+// Marks a refresh token as rotated. Only one caller can rotate a given token, so the
+// returned row is nil when the token is unknown or was already rotated.
+func rotateRefreshToken(c context.Context, userId string, refreshToken string) (*models.RefreshToken, error) {
 	db := config.MongoClient()
 
-	// Save refresh token to database
-	c, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
+	var result models.RefreshToken
+	filter := bson.M{"user_id": userId, "token_hash": util.HashRefreshToken(refreshToken), "rotated_at": bson.M{"$exists": false}}
+	err := db.Database("Chat-App").Collection("refresh_tokens").FindOneAndUpdate(c, filter,
+		bson.M{"$set": bson.M{"rotated_at": primitive.NewDateTimeFromTime(time.Now())}}).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	err := db.Database("Chat-App").Collection("refresh_tokens").FindOneAndUpdate(c,
-		bson.M{
-			"user_id":       userId,
-			"refresh_token": oldRefreshToken},
-		bson.D{{
-			Key: "$set",
-			Value: bson.M{
-				"refresh_token": newRefreshToken,
-				"created_at":    primitive.NewDateTimeFromTime(time.Now()),
-				"expires_at":    primitive.NewDateTimeFromTime(time.Now().Add(time.Second * time.Duration(expirationTime)))}}},
-		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Err()
-
-	fmt.Println(err)
-	return err == nil
+	return &result, nil
+}
+
+// Revokes a whole token family after one of its rotated tokens was presented again.
+// Either the legitimate client or an attacker holds a stale copy, and there is no
+// telling which, so every token of the family stops working.
+func handleRefreshTokenReuse(c *gin.Context, reused models.RefreshToken) {
+	db := config.MongoClient()
+
+	_, err := db.Database("Chat-App").Collection("refresh_tokens").DeleteMany(c, bson.M{"family_id": reused.FamilyID})
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	device := util.DeviceFromRequest(c)
+	util.LogSecurityEvent(c, models.SecurityEvent{
+		UserID:    reused.UserID,
+		Type:      models.SecurityEventRefreshTokenReuse,
+		FamilyID:  reused.FamilyID,
+		IPAddress: device.IPAddress,
+		UserAgent: device.UserAgent,
+	})
+}
+
+// Finds the account a verified identity signs in to, by provider and subject. Accounts
+// from before subjects were stored are found by email, but never unverified password
+// accounts, since anyone can sign up with an email they don't own.
+func findIdentityUser(ctx context.Context, provider string, account *identity.Identity) (*models.User, error) {
+	users := config.MongoClient().Database("Chat-App").Collection("users")
+
+	var user models.User
+	err := users.FindOne(ctx, bson.M{"identity_provider": provider, "identity_subject": account.Subject}).Decode(&user)
+	if err == nil {
+		return &user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	filter := bson.M{
+		"email":            account.Email,
+		"identity_subject": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"email_verified": true},
+			bson.M{"password_hash": bson.M{"$exists": false}},
+		},
+	}
+	err = users.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+
+	// Legacy provider accounts are found by subject from now on
+	if user.PasswordHash == "" {
+		_, err = users.UpdateOne(ctx, bson.M{"_id": user.ID},
+			bson.M{"$set": bson.M{"identity_provider": provider, "identity_subject": account.Subject}})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// Deletes the unverified password account using the email, if there is one. It has
+// never been signed in to, so nothing else belongs to it.
+func releaseUnverifiedEmail(ctx context.Context, email string) error {
+	db := config.MongoClient()
+
+	filter := bson.M{"email": email, "email_verified": bson.M{"$ne": true}, "password_hash": bson.M{"$exists": true}}
+	var user models.User
+	err := db.Database("Chat-App").Collection("users").FindOneAndDelete(ctx, filter).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Database("Chat-App").Collection("email_tokens").DeleteMany(ctx, bson.M{"user_id": user.ID.Hex()})
+	return err
 }
 
 func HandleLogin(c *gin.Context) {
-	admin := config.InitializeApp()
 	db := config.MongoClient()
 	var googleAccountToken GoogleToken
 
@@ -126,50 +247,45 @@ func HandleLogin(c *gin.Context) {
 		return
 	}
 
-	// Get auth client
-	client, err := admin.Auth(c)
-	if err != nil {
-		c.JSON(500, gin.H{"status": "error", "message": "Error getting Auth client"})
-		return
-	}
-
-	// Verify google account token
-	token, err := client.VerifyIDToken(c, googleAccountToken.Token)
+	// Verify account token
+	verifier := config.IdentityVerifier()
+	account, err := verifier.Verify(c, googleAccountToken.Token)
 	if err != nil {
 		c.JSON(500, gin.H{"status": "error", "message": "Error verifying account token"})
 		return
 	}
+	account.Email = util.NormalizeEmail(account.Email)
 
-	// Get user data
-	var result models.User
-	err = db.Database("Chat-App").Collection("users").FindOneAndUpdate(c, bson.D{{Key: "email", Value: token.Claims["email"]}}, bson.M{"$set": bson.M{"profile_picture": token.Claims["picture"]}}).Decode(&result)
+	// Get user data, keeping the provider's photo up to date
+	result, err := findIdentityUser(c, verifier.Name(), account)
 	if err != nil {
 		fmt.Println(err)
 		c.JSON(500, gin.H{"status": "error", "message": "User does not exist"})
 		return
 	}
-
-	// Generate access and refresh tokens.
-	accessToken, refreshToken := generateTokens(result.ID.Hex())
-	if accessToken == "" || refreshToken == "" {
-		c.JSON(500, gin.H{"status": "error", "message": "Error generating tokens"})
-		return
+	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, bson.M{"_id": result.ID}, bson.M{"$set": bson.M{"provider_picture": account.Picture}})
+	if err != nil {
+		log.Println("error updating provider picture:", err)
 	}
-	inserted := insertNewRefreshToken(result.ID.Hex(), refreshToken, 60*60*24*7)
-	if !inserted {
-		c.JSON(500, gin.H{"status": "error", "message": "Error updating refresh token"})
-		return
+	result.ProviderPicture = &account.Picture
+
+	// Only users showing the provider's photo see it change
+	profilePicture := avatar.ProfilePictureURL(*result)
+	if result.ProfilePicture == nil || *result.ProfilePicture != profilePicture {
+		_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, bson.M{"_id": result.ID}, bson.M{"$set": bson.M{"profile_picture": profilePicture}})
+		if err != nil {
+			log.Println("error updating profile picture:", err)
+		}
+		result.ProfilePicture = &profilePicture
 	}
 
-	c.JSON(200, gin.H{"status": "success", "message": "User logged in successfully", "access_token": accessToken, "refresh_token": refreshToken})
-
-	// Set offline status after 15 minutes
-	util.SetOfflineAfterDuration(result.ID.Hex(), 15*time.Minute, c)
+	// Generate access and refresh tokens, or ask for the second factor
+	respondWithLogin(c, *result, "User logged in successfully")
 }
 
 func HandleRegister(c *gin.Context) {
-	admin := config.InitializeApp()
 	db := config.MongoClient()
+	verifier := config.IdentityVerifier()
 	var accountCreation AccountCreation
 
 	err := c.BindJSON(&accountCreation)
@@ -183,62 +299,72 @@ func HandleRegister(c *gin.Context) {
 		return
 	}
 
-	// Get auth client
-	client, err := admin.Auth(c)
-	if err != nil {
-		c.JSON(500, gin.H{"status": "error", "message": "Error getting Auth client"})
-		return
-	}
-
-	// Verify google account token
-	token, err := client.VerifyIDToken(c, accountCreation.Token)
-
+	// Verify account token
+	account, err := verifier.Verify(c, accountCreation.Token)
 	if err != nil {
 		c.JSON(500, gin.H{"status": "error", "message": "Error verifying account token"})
 		return
 	}
+	account.Email = util.NormalizeEmail(account.Email)
 
-	var result models.User
-	err = db.Database("Chat-App").Collection("users").FindOne(c, bson.D{{Key: "email", Value: token.Claims["email"]}}).Decode(&result)
-
+	_, err = findIdentityUser(c, verifier.Name(), account)
 	if err == nil {
 		// User already exists
 		c.JSON(500, gin.H{"status": "error", "message": "User already exists"})
 		return
 	}
+	if err != mongo.ErrNoDocuments {
+		c.JSON(500, gin.H{"status": "error", "message": "Error creating user"})
+		return
+	}
 
-	// Create user
-	profilePicture := token.Claims["picture"].(string)
-	newUser, err := db.Database("Chat-App").Collection("users").InsertOne(c, models.User{
-		ID:             primitive.NewObjectID(),
-		FirebaseID:     token.UID,
-		Email:          token.Claims["email"].(string),
-		Username:       accountCreation.Username,
-		ProfilePicture: &profilePicture,
-		Status:         "online",
-		CustomStatus:   nil})
+	// The provider verified the email, so an unverified password account using it gives way
+	err = releaseUnverifiedEmail(c, account.Email)
 	if err != nil {
 		c.JSON(500, gin.H{"status": "error", "message": "Error creating user"})
 		return
 	}
 
-	// Generate access and refresh tokens
-	accessToken, refreshToken := generateTokens(newUser.InsertedID.(primitive.ObjectID).Hex())
-	if accessToken == "" || refreshToken == "" {
-		c.JSON(500, gin.H{"status": "error", "message": "Error generating tokens"})
+	username, usernameKey, err := util.PrepareUsername(c, accountCreation.Username, "")
+	if err != nil {
+		util.RespondWithUsernameError(c, err, "Error creating user")
 		return
 	}
 
-	inserted := insertNewRefreshToken(newUser.InsertedID.(primitive.ObjectID).Hex(), refreshToken, 60*60*24*7)
-	if !inserted {
-		c.JSON(500, gin.H{"status": "error", "message": "Error updating refresh token"})
+	// Create user
+	user := models.User{
+		ID:                primitive.NewObjectID(),
+		IdentityProvider:  verifier.Name(),
+		IdentitySubject:   account.Subject,
+		Email:             account.Email,
+		EmailVerified:     true,
+		Username:          username,
+		UsernameKey:       usernameKey,
+		UsernameSearchKey: util.UsernameSearchKey(username),
+		ProviderPicture:   &account.Picture,
+		Status:            "online",
+		CustomStatus:      nil}
+	if verifier.Name() == "firebase" {
+		user.FirebaseID = account.Subject
+	}
+	profilePicture := avatar.ProfilePictureURL(user)
+	user.ProfilePicture = &profilePicture
+	_, err = db.Database("Chat-App").Collection("users").InsertOne(c, user)
+	if isDuplicateEmail(err) {
+		c.JSON(500, gin.H{"status": "error", "message": "User already exists"})
+		return
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		util.RespondWithUsernameError(c, util.ErrUsernameTaken, "Error creating user")
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Error creating user"})
 		return
 	}
 
-	c.JSON(200, gin.H{"status": "success", "message": "User created successfully", "access_token": accessToken, "refresh_token": refreshToken})
-
-	// Set offline status after 15 minutes
-	util.SetOfflineAfterDuration(newUser.InsertedID.(primitive.ObjectID).Hex(), 15*time.Minute, c)
+	// Generate access and refresh tokens
+	respondWithNewSession(c, user, "User created successfully")
 }
 
 func HandleRevokeToken(c *gin.Context) {
@@ -262,14 +388,16 @@ func HandleRevokeToken(c *gin.Context) {
 		return
 	}
 
-	// Revoke refresh token
-	revokedToken, err := db.Database("Chat-App").Collection("refresh_tokens").DeleteOne(c, bson.M{"user_id": uid, "refresh_token": refreshToken.Token})
+	// Revoke the session the refresh token belongs to
+	var session models.RefreshToken
+	err = db.Database("Chat-App").Collection("refresh_tokens").FindOne(c, bson.M{"user_id": uid, "token_hash": util.HashRefreshToken(refreshToken.Token), "rotated_at": bson.M{"$exists": false}}).Decode(&session)
 	if err != nil {
-		c.JSON(500, gin.H{"status": "error", "message": "Error revoking refresh token"})
+		c.JSON(500, gin.H{"status": "error", "message": "Invalid refresh token"})
 		return
 	}
-	if revokedToken.DeletedCount == 0 {
-		c.JSON(500, gin.H{"status": "error", "message": "Invalid refresh token"})
+	_, err = db.Database("Chat-App").Collection("refresh_tokens").DeleteMany(c, bson.M{"family_id": session.FamilyID})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Error revoking refresh token"})
 		return
 	}
 
@@ -300,26 +428,53 @@ func HandleRefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Check if the refresh token exists in the database
-	var result models.RefreshToken
-	err = db.Database("Chat-App").Collection("refresh_tokens").FindOne(c, bson.M{"user_id": uid, "refresh_token": refreshToken.Token}).Decode(&result)
+	// Rotate the token, which only succeeds once per token
+	result, err := rotateRefreshToken(c, uid, refreshToken.Token)
 	if err != nil {
-		c.JSON(500, gin.H{"status": "error", "message": "invalid refresh token"})
+		c.JSON(500, gin.H{"status": "error", "message": "Error updating refresh token"})
+		return
+	}
+	if result == nil {
+		// A token that exists but was already rotated is being replayed
+		var reused models.RefreshToken
+		err = db.Database("Chat-App").Collection("refresh_tokens").FindOne(c, bson.M{"user_id": uid, "token_hash": util.HashRefreshToken(refreshToken.Token)}).Decode(&reused)
+		if err == nil {
+			handleRefreshTokenReuse(c, reused)
+		}
+
+		c.JSON(401, gin.H{"status": "error", "message": "invalid refresh token"})
 		return
 	}
 
-	// Delete the refresh token from the database
-	db.Database("Chat-App").Collection("refresh_tokens").DeleteOne(c, bson.M{"user_id": uid, "refresh_token": refreshToken.Token})
+	// Load the user so the new tokens carry their current role
+	objectID, err := primitive.ObjectIDFromHex(uid)
+	if err != nil {
+		c.JSON(401, gin.H{"status": "error", "message": "invalid refresh token"})
+		return
+	}
+	var user models.User
+	err = db.Database("Chat-App").Collection("users").FindOne(c, bson.M{"_id": objectID}).Decode(&user)
+	if err != nil || user.Disabled {
+		db.Database("Chat-App").Collection("refresh_tokens").DeleteMany(c, bson.M{"family_id": result.FamilyID})
+		c.JSON(401, gin.H{"status": "error", "message": "invalid refresh token"})
+		return
+	}
 
-	// Generate new tokens
-	accessToken, newRefreshToken := generateTokens(uid)
+	// Generate new tokens for the same family
+	accessToken, newRefreshToken := generateTokens(user, result.FamilyID.Hex())
 	if accessToken == "" || newRefreshToken == "" {
 		c.JSON(500, gin.H{"status": "error", "message": "Error generating tokens"})
 		return
 	}
 
-	updated := updateRefreshToken(refreshToken.Token, newRefreshToken, uid, 60*60*24*7)
-	if !updated {
+	device := util.DeviceFromRequest(c)
+	session := *result
+	session.IPAddress = device.IPAddress
+	session.UserAgent = device.UserAgent
+	inserted := insertNewRefreshToken(session, newRefreshToken, 60*60*24*7)
+	if !inserted {
+		// Undo the rotation so the client can retry with the same token
+		db.Database("Chat-App").Collection("refresh_tokens").UpdateOne(c, bson.M{"_id": result.ID}, bson.M{"$unset": bson.M{"rotated_at": ""}})
 		c.JSON(500, gin.H{"status": "error", "message": "Error updating refresh token"})
 		return
 	}
@@ -330,6 +485,40 @@ func HandleRefreshToken(c *gin.Context) {
 	util.SetOfflineAfterDuration(uid, 15*time.Minute, c)
 }
 
+// Issues account tokens from the fake identity provider for local development.
+func HandleFakeIdentityToken(c *gin.Context) {
+	verifier, ok := config.IdentityVerifier().(*identity.FakeVerifier)
+	if !ok {
+		c.JSON(404, gin.H{"status": "error", "message": "Not found"})
+		return
+	}
+
+	var body FakeIdentityTokenBody
+	err := c.BindJSON(&body)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(body)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	subject := body.Subject
+	if subject == "" {
+		subject = body.Email
+	}
+
+	token, err := verifier.SignToken(identity.Identity{Subject: subject, Email: body.Email, Picture: body.Picture}, time.Hour)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Error signing account token"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "account_token": token})
+}
+
 func AuthenticationRoutes(route *gin.RouterGroup) {
 	authGroup := route.Group("/")
 	{
@@ -337,5 +526,17 @@ func AuthenticationRoutes(route *gin.RouterGroup) {
 		authGroup.POST("register", HandleRegister)
 		authGroup.POST("revoke", HandleRevokeToken)
 		authGroup.POST("refresh_token", HandleRefreshToken)
+
+		authGroup.POST("mfa/verify", HandleVerifyMFA)
+		authGroup.POST("password/register", HandlePasswordRegister)
+		authGroup.POST("password/login", HandlePasswordLogin)
+		authGroup.POST("password/verify_email", HandleVerifyEmail)
+		authGroup.POST("password/resend_verification", HandleResendVerification)
+		authGroup.POST("password/request_reset", HandleRequestPasswordReset)
+		authGroup.POST("password/reset", HandleResetPassword)
+
+		if os.Getenv("IDENTITY_PROVIDER") == "fake" {
+			authGroup.POST("fake_identity_token", HandleFakeIdentityToken)
+		}
 	}
 }
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	config "chat-app-back/src/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Publishes the public keys tokens are signed with so other services can verify them.
+func HandleJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(200, config.SigningKeys().JWKS())
+}
+
+func WellKnownRoutes(route *gin.RouterGroup) {
+	wellKnownGroup := route.Group("/")
+	{
+		wellKnownGroup.GET("jwks.json", HandleJWKS)
+	}
+}
@@ -0,0 +1,117 @@
+package routes
+
+import (
+	config "chat-app-back/src/config"
+	models "chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MFAVerification struct {
+	Token        string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+const (
+	mfaChallengeLifetime = 5 * time.Minute
+	maxMFAAttempts       = 5
+)
+
+// Finishes a login that passed the first factor. Users with two-factor authentication
+// get a short-lived mfa_pending token to exchange for the token pair, everyone else
+// gets the token pair right away.
+func respondWithLogin(c *gin.Context, user models.User, message string) {
+	db := config.MongoClient()
+
+	if user.Disabled {
+		c.JSON(403, gin.H{"status": "error", "message": "Account disabled"})
+		return
+	}
+
+	if !user.TOTPEnabled {
+		respondWithNewSession(c, user, message)
+		return
+	}
+
+	token, err := util.GenerateRandomToken()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Error generating tokens"})
+		return
+	}
+
+	_, err = db.Database("Chat-App").Collection("mfa_challenges").InsertOne(c, models.MFAChallenge{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID.Hex(),
+		TokenHash: util.HashToken(token),
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+		ExpiresAt: primitive.NewDateTimeFromTime(time.Now().Add(mfaChallengeLifetime)),
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Error generating tokens"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "mfa_pending", "message": "Two-factor authentication code required", "mfa_token": token, "expires_in": int(mfaChallengeLifetime.Seconds())})
+}
+
+func HandleVerifyMFA(c *gin.Context) {
+	db := config.MongoClient()
+	var verification MFAVerification
+
+	err := c.BindJSON(&verification)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(verification)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	// Count the attempt before checking the code so guesses are capped per challenge
+	var challenge models.MFAChallenge
+	filter := bson.M{
+		"token_hash": util.HashToken(verification.Token),
+		"expires_at": bson.M{"$gt": primitive.NewDateTimeFromTime(time.Now())},
+		"attempts":   bson.M{"$lt": maxMFAAttempts},
+	}
+	err = db.Database("Chat-App").Collection("mfa_challenges").FindOneAndUpdate(c, filter, bson.M{"$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&challenge)
+	if err != nil {
+		c.JSON(401, gin.H{"status": "error", "message": "Invalid or expired mfa token"})
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(challenge.UserID)
+	if err != nil {
+		c.JSON(401, gin.H{"status": "error", "message": "Invalid or expired mfa token"})
+		return
+	}
+
+	var user models.User
+	err = db.Database("Chat-App").Collection("users").FindOne(c, bson.M{"_id": objectID}).Decode(&user)
+	if err != nil {
+		c.JSON(401, gin.H{"status": "error", "message": "Invalid or expired mfa token"})
+		return
+	}
+
+	if !util.VerifySecondFactor(c, user, verification.Code, verification.RecoveryCode) {
+		c.JSON(401, gin.H{"status": "error", "message": "Invalid code"})
+		return
+	}
+
+	_, err = db.Database("Chat-App").Collection("mfa_challenges").DeleteOne(c, bson.M{"_id": challenge.ID})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Error verifying code"})
+		return
+	}
+
+	respondWithNewSession(c, user, "User logged in successfully")
+}
@@ -0,0 +1,722 @@
+package routes
+
+import (
+	config "chat-app-back/src/config"
+	"chat-app-back/src/middlewares"
+	models "chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RegisterOAuthClient struct {
+	Name         string   `json:"name" validate:"required,max=64"`
+	RedirectURIs []string `json:"redirect_uris" validate:"required,min=1,max=10,dive,required,max=2048"`
+	Scopes       []string `json:"scopes" validate:"required,min=1,dive,oneof=messages:read messages:write profile:read"`
+	Confidential bool     `json:"confidential"`
+}
+
+type OAuthClientAction struct {
+	ClientID string `json:"client_id" validate:"required"`
+}
+
+// The parameters of an authorization request. The consent screen of the client
+// app reads them from the redirect and posts them back with the user's answer.
+type AuthorizeRequest struct {
+	ClientID            string `json:"client_id" form:"client_id" validate:"required"`
+	RedirectURI         string `json:"redirect_uri" form:"redirect_uri" validate:"required"`
+	ResponseType        string `json:"response_type" form:"response_type" validate:"required,eq=code"`
+	Scope               string `json:"scope" form:"scope" validate:"required"`
+	State               string `json:"state" form:"state" validate:"max=512"`
+	CodeChallenge       string `json:"code_challenge" form:"code_challenge" validate:"required,min=43,max=128"`
+	CodeChallengeMethod string `json:"code_challenge_method" form:"code_challenge_method" validate:"required,eq=S256"`
+	Approve             bool   `json:"approve" form:"-"`
+}
+
+type OAuthClientResponse struct {
+	ClientID     string             `json:"client_id"`
+	Name         string             `json:"name"`
+	RedirectURIs []string           `json:"redirect_uris"`
+	Scopes       []string           `json:"scopes"`
+	Confidential bool               `json:"confidential"`
+	CreatedAt    primitive.DateTime `json:"created_at"`
+}
+
+type OAuthGrantResponse struct {
+	ClientID  string             `json:"client_id"`
+	Name      string             `json:"name"`
+	Scopes    []string           `json:"scopes"`
+	UpdatedAt primitive.DateTime `json:"updated_at"`
+}
+
+const (
+	maxOAuthClientsPerUser = 10
+
+	oauthCodeLifetime                = 10 * time.Minute
+	oauthAccessTokenLifetime   int64 = 60 * 60
+	oauthRefreshTokenLifetime  int64 = 60 * 60 * 24 * 30
+	oauthClientIDLength              = 24
+	oauthCodeVerifierMinLength       = 43
+	oauthCodeVerifierMaxLength       = 128
+)
+
+// Redirect URIs must use https, except on the loopback interface during development.
+func validRedirectURI(redirectURI string) bool {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil || parsed.Host == "" || parsed.Fragment != "" || parsed.User != nil {
+		return false
+	}
+
+	switch parsed.Scheme {
+	case "https":
+		return true
+	case "http":
+		hostname := parsed.Hostname()
+		return hostname == "localhost" || hostname == "127.0.0.1" || hostname == "::1"
+	default:
+		return false
+	}
+}
+
+// Splits a space separated scope parameter. Every scope must be allowed for the client.
+func parseScopes(scope string, allowed []string) ([]string, bool) {
+	scopes := strings.Fields(scope)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+	if len(scopes) == 0 {
+		return nil, false
+	}
+
+	for _, s := range scopes {
+		if !slices.Contains(allowed, s) {
+			return nil, false
+		}
+	}
+
+	return scopes, true
+}
+
+// Adds the parameters to the query of a redirect URI.
+func redirectWithParams(redirectURI string, params map[string]string) string {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := parsed.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
+
+// Checks a PKCE code verifier against the S256 challenge from the authorization request.
+func verifyCodeChallenge(verifier string, challenge string) bool {
+	if len(verifier) < oauthCodeVerifierMinLength || len(verifier) > oauthCodeVerifierMaxLength {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func findOAuthClient(c *gin.Context, clientID string) (*models.OAuthClient, error) {
+	db := config.MongoClient()
+
+	var client models.OAuthClient
+	err := db.Database("Chat-App").Collection("oauth_clients").FindOne(c, bson.M{"client_id": clientID}).Decode(&client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+// Validates an authorization request against the registered client and returns the
+// client and the requested scopes. It responds when the request is invalid.
+func validateAuthorizeRequest(c *gin.Context, request AuthorizeRequest) (*models.OAuthClient, []string, bool) {
+	err := validate.Struct(request)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid authorization request"})
+		return nil, nil, false
+	}
+
+	client, err := findOAuthClient(c, request.ClientID)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Unknown client"})
+		return nil, nil, false
+	}
+	if !slices.Contains(client.RedirectURIs, request.RedirectURI) {
+		c.JSON(400, gin.H{"status": "error", "message": "Redirect URI not registered for this client"})
+		return nil, nil, false
+	}
+
+	scopes, ok := parseScopes(request.Scope, client.Scopes)
+	if !ok {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid scope"})
+		return nil, nil, false
+	}
+
+	return client, scopes, true
+}
+
+func HandleRegisterOAuthClient(c *gin.Context) {
+	db := config.MongoClient()
+
+	var registration RegisterOAuthClient
+	err := c.BindJSON(&registration)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(registration)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	for _, redirectURI := range registration.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			c.JSON(400, gin.H{"status": "error", "message": "Redirect URIs must use https"})
+			return
+		}
+	}
+
+	uid := util.GetUid(c)
+	count, err := db.Database("Chat-App").Collection("oauth_clients").CountDocuments(c, bson.M{"owner_id": uid})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to register client"})
+		return
+	}
+	if count >= maxOAuthClientsPerUser {
+		c.JSON(400, gin.H{"status": "error", "message": "Client limit reached"})
+		return
+	}
+
+	clientID, err := util.GenerateRandomToken()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to register client"})
+		return
+	}
+
+	scopes := slices.Clone(registration.Scopes)
+	slices.Sort(scopes)
+	client := models.OAuthClient{
+		ID:           primitive.NewObjectID(),
+		ClientID:     clientID[:oauthClientIDLength],
+		OwnerID:      uid,
+		Name:         registration.Name,
+		RedirectURIs: registration.RedirectURIs,
+		Scopes:       slices.Compact(scopes),
+		CreatedAt:    primitive.NewDateTimeFromTime(time.Now()),
+	}
+
+	// Confidential clients also authenticate with a secret, which is only returned once
+	var clientSecret string
+	if registration.Confidential {
+		clientSecret, err = util.GenerateRandomToken()
+		if err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to register client"})
+			return
+		}
+		client.SecretHash = util.HashToken(clientSecret)
+	}
+
+	_, err = db.Database("Chat-App").Collection("oauth_clients").InsertOne(c, client)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to register client"})
+		return
+	}
+
+	response := gin.H{"status": "success", "message": "Client registered successfully", "client_id": client.ClientID}
+	if clientSecret != "" {
+		response["client_secret"] = clientSecret
+	}
+	c.JSON(200, response)
+}
+
+func HandleGetOAuthClients(c *gin.Context) {
+	db := config.MongoClient()
+
+	cursor, err := db.Database("Chat-App").Collection("oauth_clients").Find(c, bson.M{"owner_id": util.GetUid(c)}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch clients"})
+		return
+	}
+	defer cursor.Close(c)
+
+	clients := []OAuthClientResponse{}
+	for cursor.Next(c) {
+		var client models.OAuthClient
+		if err := cursor.Decode(&client); err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch clients"})
+			return
+		}
+
+		clients = append(clients, OAuthClientResponse{
+			ClientID:     client.ClientID,
+			Name:         client.Name,
+			RedirectURIs: client.RedirectURIs,
+			Scopes:       client.Scopes,
+			Confidential: client.SecretHash != "",
+			CreatedAt:    client.CreatedAt,
+		})
+	}
+
+	if err := cursor.Err(); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch clients"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "clients": clients})
+}
+
+// Deletes a client along with everything issued to it.
+func HandleDeleteOAuthClient(c *gin.Context) {
+	db := config.MongoClient()
+
+	var action OAuthClientAction
+	err := c.BindJSON(&action)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(action)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	result, err := db.Database("Chat-App").Collection("oauth_clients").DeleteOne(c, bson.M{"client_id": action.ClientID, "owner_id": util.GetUid(c)})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to delete client"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(404, gin.H{"status": "error", "message": "Client not found"})
+		return
+	}
+
+	filter := bson.M{"client_id": action.ClientID}
+	for _, collection := range []string{"oauth_codes", "oauth_grants", "refresh_tokens"} {
+		_, err := db.Database("Chat-App").Collection(collection).DeleteMany(c, filter)
+		if err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to delete client"})
+			return
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Client deleted successfully"})
+}
+
+// Describes an authorization request so the consent screen can show the client
+// and the scopes it asks for.
+func HandleGetAuthorize(c *gin.Context) {
+	db := config.MongoClient()
+
+	var request AuthorizeRequest
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid authorization request"})
+		return
+	}
+
+	client, scopes, ok := validateAuthorizeRequest(c, request)
+	if !ok {
+		return
+	}
+
+	var grant models.OAuthGrant
+	grantedScopes := []string{}
+	err = db.Database("Chat-App").Collection("oauth_grants").FindOne(c, bson.M{"user_id": util.GetUid(c), "client_id": client.ClientID}).Decode(&grant)
+	if err == nil {
+		grantedScopes = grant.Scopes
+	}
+
+	c.JSON(200, gin.H{
+		"status":         "success",
+		"client":         gin.H{"client_id": client.ClientID, "name": client.Name},
+		"scopes":         scopes,
+		"granted_scopes": grantedScopes,
+	})
+}
+
+// Records the user's answer to the consent screen and returns the URI to send them
+// back to the client with, carrying an authorization code or an access_denied error.
+func HandlePostAuthorize(c *gin.Context) {
+	db := config.MongoClient()
+
+	var request AuthorizeRequest
+	err := c.BindJSON(&request)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	client, scopes, ok := validateAuthorizeRequest(c, request)
+	if !ok {
+		return
+	}
+
+	if !request.Approve {
+		redirectURI := redirectWithParams(request.RedirectURI, map[string]string{"error": "access_denied", "state": request.State})
+		c.JSON(200, gin.H{"status": "success", "redirect_uri": redirectURI})
+		return
+	}
+
+	uid := util.GetUid(c)
+	now := primitive.NewDateTimeFromTime(time.Now())
+
+	// Remember the consent so the user can review and revoke it later
+	_, err = db.Database("Chat-App").Collection("oauth_grants").UpdateOne(c,
+		bson.M{"user_id": uid, "client_id": client.ClientID},
+		bson.M{
+			"$addToSet":    bson.M{"scopes": bson.M{"$each": scopes}},
+			"$set":         bson.M{"updated_at": now},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to authorize client"})
+		return
+	}
+
+	code, err := util.GenerateRandomToken()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to authorize client"})
+		return
+	}
+
+	_, err = db.Database("Chat-App").Collection("oauth_codes").InsertOne(c, models.OAuthCode{
+		ID:            primitive.NewObjectID(),
+		CodeHash:      util.HashToken(code),
+		ClientID:      client.ClientID,
+		UserID:        uid,
+		RedirectURI:   request.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: request.CodeChallenge,
+		CreatedAt:     now,
+		ExpiresAt:     primitive.NewDateTimeFromTime(time.Now().Add(oauthCodeLifetime)),
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to authorize client"})
+		return
+	}
+
+	redirectURI := redirectWithParams(request.RedirectURI, map[string]string{"code": code, "state": request.State})
+	c.JSON(200, gin.H{"status": "success", "redirect_uri": redirectURI})
+}
+
+func HandleGetOAuthGrants(c *gin.Context) {
+	db := config.MongoClient()
+
+	cursor, err := db.Database("Chat-App").Collection("oauth_grants").Find(c, bson.M{"user_id": util.GetUid(c)}, options.Find().SetSort(bson.M{"updated_at": -1}))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch authorized apps"})
+		return
+	}
+	defer cursor.Close(c)
+
+	grants := []OAuthGrantResponse{}
+	for cursor.Next(c) {
+		var grant models.OAuthGrant
+		if err := cursor.Decode(&grant); err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch authorized apps"})
+			return
+		}
+
+		response := OAuthGrantResponse{ClientID: grant.ClientID, Scopes: grant.Scopes, UpdatedAt: grant.UpdatedAt}
+		if client, err := findOAuthClient(c, grant.ClientID); err == nil {
+			response.Name = client.Name
+		}
+		grants = append(grants, response)
+	}
+
+	if err := cursor.Err(); err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to fetch authorized apps"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "apps": grants})
+}
+
+// Withdraws the user's consent for a client and logs the client out.
+func HandleRevokeOAuthGrant(c *gin.Context) {
+	db := config.MongoClient()
+
+	var action OAuthClientAction
+	err := c.BindJSON(&action)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(action)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	filter := bson.M{"user_id": util.GetUid(c), "client_id": action.ClientID}
+	result, err := db.Database("Chat-App").Collection("oauth_grants").DeleteOne(c, filter)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Failed to revoke app"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(404, gin.H{"status": "error", "message": "App not found"})
+		return
+	}
+
+	for _, collection := range []string{"oauth_codes", "refresh_tokens"} {
+		_, err := db.Database("Chat-App").Collection(collection).DeleteMany(c, filter)
+		if err != nil {
+			c.JSON(500, gin.H{"status": "error", "message": "Failed to revoke app"})
+			return
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "App revoked successfully"})
+}
+
+// Responds with an OAuth2 error, as the token endpoint must.
+func oauthError(c *gin.Context, code int, oauthErr string, description string) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(code, gin.H{"error": oauthErr, "error_description": description})
+}
+
+// Authenticates the client calling the token endpoint, from HTTP basic auth or the
+// form. Confidential clients must present their secret.
+func authenticateOAuthClient(c *gin.Context) (*models.OAuthClient, bool) {
+	clientID, clientSecret, basic := c.Request.BasicAuth()
+	if !basic {
+		clientID = c.PostForm("client_id")
+		clientSecret = c.PostForm("client_secret")
+	}
+
+	client, err := findOAuthClient(c, clientID)
+	if err != nil {
+		oauthError(c, 401, "invalid_client", "Unknown client")
+		return nil, false
+	}
+
+	if client.SecretHash != "" {
+		hash := util.HashToken(clientSecret)
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash)) != 1 {
+			oauthError(c, 401, "invalid_client", "Invalid client secret")
+			return nil, false
+		}
+	}
+
+	return client, true
+}
+
+// Issues a token pair for a session of the client and responds with both. The tokens
+// carry the granted scopes and no role, so the app cannot use the user's privileges.
+func respondWithOAuthTokens(c *gin.Context, session models.RefreshToken) {
+	accessToken, err := util.GenerateToken(session.UserID, session.FamilyID.Hex(), "", session.Scopes, oauthAccessTokenLifetime, false)
+	if err != nil {
+		oauthError(c, 500, "server_error", "Error generating tokens")
+		return
+	}
+	refreshToken, err := util.GenerateToken(session.UserID, session.FamilyID.Hex(), "", session.Scopes, oauthRefreshTokenLifetime, true)
+	if err != nil {
+		oauthError(c, 500, "server_error", "Error generating tokens")
+		return
+	}
+
+	if !insertNewRefreshToken(session, refreshToken, oauthRefreshTokenLifetime) {
+		oauthError(c, 500, "server_error", "Error generating tokens")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(200, gin.H{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    oauthAccessTokenLifetime,
+		"refresh_token": refreshToken,
+		"scope":         strings.Join(session.Scopes, " "),
+	})
+}
+
+// Loads the user a token is being issued for. Disabled users get no new tokens.
+func findActiveUser(c *gin.Context, uid string) (*models.User, bool) {
+	db := config.MongoClient()
+
+	objectID, err := primitive.ObjectIDFromHex(uid)
+	if err != nil {
+		return nil, false
+	}
+
+	var user models.User
+	err = db.Database("Chat-App").Collection("users").FindOne(c, bson.M{"_id": objectID}).Decode(&user)
+	if err != nil || user.Disabled {
+		return nil, false
+	}
+
+	return &user, true
+}
+
+func handleAuthorizationCodeGrant(c *gin.Context, client *models.OAuthClient) {
+	db := config.MongoClient()
+
+	// Codes are single use, so the code is deleted as it is read
+	var code models.OAuthCode
+	filter := bson.M{
+		"code_hash":  util.HashToken(c.PostForm("code")),
+		"expires_at": bson.M{"$gt": primitive.NewDateTimeFromTime(time.Now())},
+	}
+	err := db.Database("Chat-App").Collection("oauth_codes").FindOneAndDelete(c, filter).Decode(&code)
+	if err != nil {
+		oauthError(c, 400, "invalid_grant", "Invalid or expired authorization code")
+		return
+	}
+
+	if code.ClientID != client.ClientID || code.RedirectURI != c.PostForm("redirect_uri") {
+		oauthError(c, 400, "invalid_grant", "Authorization code was issued to another client")
+		return
+	}
+	if !verifyCodeChallenge(c.PostForm("code_verifier"), code.CodeChallenge) {
+		oauthError(c, 400, "invalid_grant", "Invalid code verifier")
+		return
+	}
+
+	user, ok := findActiveUser(c, code.UserID)
+	if !ok {
+		oauthError(c, 400, "invalid_grant", "User not found")
+		return
+	}
+
+	device := util.DeviceFromRequest(c)
+	respondWithOAuthTokens(c, models.RefreshToken{
+		FamilyID:   primitive.NewObjectID(),
+		UserID:     user.ID.Hex(),
+		ClientID:   client.ClientID,
+		Scopes:     code.Scopes,
+		DeviceName: client.Name,
+		IPAddress:  device.IPAddress,
+		UserAgent:  device.UserAgent,
+		CreatedAt:  primitive.NewDateTimeFromTime(time.Now()),
+	})
+}
+
+func handleOAuthRefreshTokenGrant(c *gin.Context, client *models.OAuthClient) {
+	db := config.MongoClient()
+
+	refreshToken := c.PostForm("refresh_token")
+	claims, err := util.ParseToken(refreshToken, util.TokenTypeRefresh)
+	if err != nil {
+		oauthError(c, 400, "invalid_grant", "Invalid refresh token")
+		return
+	}
+
+	// Make sure the token belongs to this client before rotating it
+	var current models.RefreshToken
+	err = db.Database("Chat-App").Collection("refresh_tokens").FindOne(c, bson.M{"user_id": claims.UID, "token_hash": util.HashRefreshToken(refreshToken)}).Decode(&current)
+	if err != nil || current.ClientID != client.ClientID {
+		oauthError(c, 400, "invalid_grant", "Invalid refresh token")
+		return
+	}
+
+	result, err := rotateRefreshToken(c, claims.UID, refreshToken)
+	if err != nil {
+		oauthError(c, 500, "server_error", "Error updating refresh token")
+		return
+	}
+	if result == nil {
+		// The token was already rotated, so it is being replayed
+		handleRefreshTokenReuse(c, current)
+		oauthError(c, 400, "invalid_grant", "Invalid refresh token")
+		return
+	}
+
+	if _, ok := findActiveUser(c, claims.UID); !ok {
+		db.Database("Chat-App").Collection("refresh_tokens").DeleteMany(c, bson.M{"family_id": result.FamilyID})
+		oauthError(c, 400, "invalid_grant", "Invalid refresh token")
+		return
+	}
+
+	device := util.DeviceFromRequest(c)
+	session := *result
+	session.IPAddress = device.IPAddress
+	session.UserAgent = device.UserAgent
+	respondWithOAuthTokens(c, session)
+}
+
+// The OAuth2 token endpoint. It exchanges authorization codes and refresh tokens
+// issued to third-party apps for new token pairs.
+func HandleOAuthToken(c *gin.Context) {
+	client, ok := authenticateOAuthClient(c)
+	if !ok {
+		return
+	}
+
+	switch c.PostForm("grant_type") {
+	case "authorization_code":
+		handleAuthorizationCodeGrant(c, client)
+	case "refresh_token":
+		handleOAuthRefreshTokenGrant(c, client)
+	default:
+		oauthError(c, 400, "unsupported_grant_type", "Unsupported grant type")
+	}
+}
+
+// Lets a client revoke one of its refresh tokens, which ends that session.
+// Unknown tokens are not an error.
+func HandleOAuthRevoke(c *gin.Context) {
+	db := config.MongoClient()
+
+	client, ok := authenticateOAuthClient(c)
+	if !ok {
+		return
+	}
+
+	var session models.RefreshToken
+	filter := bson.M{"token_hash": util.HashRefreshToken(c.PostForm("token")), "client_id": client.ClientID}
+	err := db.Database("Chat-App").Collection("refresh_tokens").FindOne(c, filter).Decode(&session)
+	if err != nil && err != mongo.ErrNoDocuments {
+		oauthError(c, 503, "temporarily_unavailable", "Error revoking token")
+		return
+	}
+	if err == nil {
+		_, err = db.Database("Chat-App").Collection("refresh_tokens").DeleteMany(c, bson.M{"family_id": session.FamilyID})
+		if err != nil {
+			oauthError(c, 503, "temporarily_unavailable", "Error revoking token")
+			return
+		}
+	}
+
+	c.Status(200)
+}
+
+func OAuthRoutes(route *gin.RouterGroup) {
+	oauthGroup := route.Group("/")
+	{
+		oauthGroup.POST("token", HandleOAuthToken)
+		oauthGroup.POST("revoke", HandleOAuthRevoke)
+
+		oauthGroup.GET("authorize", middlewares.AuthenticateAccessToken(), HandleGetAuthorize)
+		oauthGroup.POST("authorize", middlewares.AuthenticateAccessToken(), HandlePostAuthorize)
+
+		oauthGroup.POST("register_client", middlewares.AuthenticateAccessToken(), HandleRegisterOAuthClient)
+		oauthGroup.GET("clients", middlewares.AuthenticateAccessToken(), HandleGetOAuthClients)
+		oauthGroup.POST("delete_client", middlewares.AuthenticateAccessToken(), HandleDeleteOAuthClient)
+		oauthGroup.GET("apps", middlewares.AuthenticateAccessToken(), HandleGetOAuthGrants)
+		oauthGroup.POST("revoke_app", middlewares.AuthenticateAccessToken(), HandleRevokeOAuthGrant)
+	}
+}
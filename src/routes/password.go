@@ -0,0 +1,384 @@
+package routes
+
+import (
+	"chat-app-back/src/avatar"
+	config "chat-app-back/src/config"
+	"chat-app-back/src/mailer"
+	models "chat-app-back/src/models"
+	"chat-app-back/src/util"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type PasswordRegistration struct {
+	Username string `json:"username" validate:"required"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+type PasswordLogin struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type EmailTokenBody struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type EmailBody struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type PasswordReset struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+const (
+	verifyEmailTokenLifetime   = 24 * time.Hour
+	resetPasswordTokenLifetime = time.Hour
+)
+
+// Compared against when the user does not exist so failed logins take the same time.
+var dummyPasswordHash, _ = util.HashPassword("dummy password")
+
+// Reports whether the insert failed because the email is already used.
+func isDuplicateEmail(err error) bool {
+	return mongo.IsDuplicateKeyError(err) && strings.Contains(err.Error(), "index: email_unique ")
+}
+
+// Builds a link to a page of the client app carrying the token.
+func emailTokenLink(path string, token string) string {
+	return fmt.Sprintf("%s/%s?token=%s", strings.TrimSuffix(os.Getenv("APP_URL"), "/"), path, url.QueryEscape(token))
+}
+
+// Creates a single use token for the user and emails them a link containing it.
+func sendEmailToken(ctx context.Context, user models.User, purpose string) error {
+	db := config.MongoClient()
+
+	token, err := util.GenerateRandomToken()
+	if err != nil {
+		return err
+	}
+
+	lifetime := verifyEmailTokenLifetime
+	message := mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body:    fmt.Sprintf("Hi %s,\n\nOpen this link to verify your email:\n%s\n", user.Username, emailTokenLink("verify-email", token)),
+	}
+	if purpose == models.EmailTokenResetPassword {
+		lifetime = resetPasswordTokenLifetime
+		message.Subject = "Reset your password"
+		message.Body = fmt.Sprintf("Hi %s,\n\nOpen this link to choose a new password:\n%s\n\nIf you did not ask for this you can ignore this email.\n", user.Username, emailTokenLink("reset-password", token))
+	}
+
+	// Only the latest token of each purpose stays valid
+	_, err = db.Database("Chat-App").Collection("email_tokens").DeleteMany(ctx, bson.M{"user_id": user.ID.Hex(), "purpose": purpose})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Database("Chat-App").Collection("email_tokens").InsertOne(ctx, models.EmailToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID.Hex(),
+		Purpose:   purpose,
+		TokenHash: util.HashToken(token),
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+		ExpiresAt: primitive.NewDateTimeFromTime(time.Now().Add(lifetime)),
+	})
+	if err != nil {
+		return err
+	}
+
+	return config.Mailer().Send(ctx, message)
+}
+
+// Consumes a token and returns the user it was issued to.
+func consumeEmailToken(ctx context.Context, token string, purpose string) (*models.User, error) {
+	db := config.MongoClient()
+
+	var emailToken models.EmailToken
+	filter := bson.M{
+		"token_hash": util.HashToken(token),
+		"purpose":    purpose,
+		"expires_at": bson.M{"$gt": primitive.NewDateTimeFromTime(time.Now())},
+	}
+	err := db.Database("Chat-App").Collection("email_tokens").FindOneAndDelete(ctx, filter).Decode(&emailToken)
+	if err != nil {
+		return nil, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(emailToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err = db.Database("Chat-App").Collection("users").FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func HandlePasswordRegister(c *gin.Context) {
+	db := config.MongoClient()
+	var registration PasswordRegistration
+
+	err := c.BindJSON(&registration)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(registration)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	if len(registration.Password) > util.MaxPasswordBytes {
+		c.JSON(400, gin.H{"status": "error", "message": "Password is too long"})
+		return
+	}
+
+	email := util.NormalizeEmail(registration.Email)
+	count, err := db.Database("Chat-App").Collection("users").CountDocuments(c, bson.M{"email": email})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Error creating user"})
+		return
+	}
+	if count > 0 {
+		c.JSON(409, gin.H{"status": "error", "message": "User already exists"})
+		return
+	}
+
+	username, usernameKey, err := util.PrepareUsername(c, registration.Username, "")
+	if err != nil {
+		util.RespondWithUsernameError(c, err, "Error creating user")
+		return
+	}
+
+	passwordHash, err := util.HashPassword(registration.Password)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Error creating user"})
+		return
+	}
+
+	user := models.User{
+		ID:                primitive.NewObjectID(),
+		IdentityProvider:  "password",
+		Email:             email,
+		EmailVerified:     false,
+		PasswordHash:      passwordHash,
+		Username:          username,
+		UsernameKey:       usernameKey,
+		UsernameSearchKey: util.UsernameSearchKey(username),
+		Status:            "offline",
+		CustomStatus:      nil}
+	profilePicture := avatar.ProfilePictureURL(user)
+	user.ProfilePicture = &profilePicture
+	_, err = db.Database("Chat-App").Collection("users").InsertOne(c, user)
+	if isDuplicateEmail(err) {
+		c.JSON(409, gin.H{"status": "error", "message": "User already exists"})
+		return
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		util.RespondWithUsernameError(c, util.ErrUsernameTaken, "Error creating user")
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Error creating user"})
+		return
+	}
+
+	err = sendEmailToken(c, user, models.EmailTokenVerifyEmail)
+	if err != nil {
+		log.Println("error sending verification email:", err)
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "User created successfully, check your email to verify your account"})
+}
+
+func HandlePasswordLogin(c *gin.Context) {
+	db := config.MongoClient()
+	var login PasswordLogin
+
+	err := c.BindJSON(&login)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(login)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	var user models.User
+	err = db.Database("Chat-App").Collection("users").FindOne(c, bson.M{"email": util.NormalizeEmail(login.Email)}).Decode(&user)
+	if err != nil && err != mongo.ErrNoDocuments {
+		c.JSON(500, gin.H{"status": "error", "message": "Error logging in"})
+		return
+	}
+
+	passwordHash := user.PasswordHash
+	if passwordHash == "" {
+		passwordHash = dummyPasswordHash
+	}
+	if !util.CheckPassword(passwordHash, login.Password) || user.PasswordHash == "" {
+		c.JSON(401, gin.H{"status": "error", "message": "Invalid email or password"})
+		return
+	}
+
+	if !user.EmailVerified {
+		c.JSON(403, gin.H{"status": "error", "message": "Email not verified"})
+		return
+	}
+
+	respondWithLogin(c, user, "User logged in successfully")
+}
+
+func HandleVerifyEmail(c *gin.Context) {
+	db := config.MongoClient()
+	var body EmailTokenBody
+
+	err := c.BindJSON(&body)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(body)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	user, err := consumeEmailToken(c, body.Token, models.EmailTokenVerifyEmail)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid or expired token"})
+		return
+	}
+
+	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"email_verified": true}})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Error verifying email"})
+		return
+	}
+
+	respondWithLogin(c, *user, "Email verified successfully")
+}
+
+func HandleResendVerification(c *gin.Context) {
+	db := config.MongoClient()
+	var body EmailBody
+
+	err := c.BindJSON(&body)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(body)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	// Respond the same way whether or not the account exists
+	var user models.User
+	filter := bson.M{"email": util.NormalizeEmail(body.Email), "email_verified": bson.M{"$ne": true}, "password_hash": bson.M{"$exists": true}}
+	err = db.Database("Chat-App").Collection("users").FindOne(c, filter).Decode(&user)
+	if err == nil {
+		if err := sendEmailToken(c, user, models.EmailTokenVerifyEmail); err != nil {
+			log.Println("error sending verification email:", err)
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "If the account exists a verification email was sent"})
+}
+
+func HandleRequestPasswordReset(c *gin.Context) {
+	db := config.MongoClient()
+	var body EmailBody
+
+	err := c.BindJSON(&body)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(body)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	// Respond the same way whether or not the account exists
+	var user models.User
+	err = db.Database("Chat-App").Collection("users").FindOne(c, bson.M{"email": util.NormalizeEmail(body.Email)}).Decode(&user)
+	if err == nil {
+		if err := sendEmailToken(c, user, models.EmailTokenResetPassword); err != nil {
+			log.Println("error sending password reset email:", err)
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "If the account exists a password reset email was sent"})
+}
+
+func HandleResetPassword(c *gin.Context) {
+	db := config.MongoClient()
+	var body PasswordReset
+
+	err := c.BindJSON(&body)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	err = validate.Struct(body)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid request body"})
+		return
+	}
+	if len(body.Password) > util.MaxPasswordBytes {
+		c.JSON(400, gin.H{"status": "error", "message": "Password is too long"})
+		return
+	}
+
+	user, err := consumeEmailToken(c, body.Token, models.EmailTokenResetPassword)
+	if err != nil {
+		c.JSON(400, gin.H{"status": "error", "message": "Invalid or expired token"})
+		return
+	}
+
+	passwordHash, err := util.HashPassword(body.Password)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Error resetting password"})
+		return
+	}
+
+	// Following the link proves the user owns the email
+	update := bson.M{"$set": bson.M{"password_hash": passwordHash, "email_verified": true}}
+	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, bson.M{"_id": user.ID}, update)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Error resetting password"})
+		return
+	}
+
+	// Log out every existing session
+	_, err = db.Database("Chat-App").Collection("refresh_tokens").DeleteMany(c, bson.M{"user_id": user.ID.Hex()})
+	if err != nil {
+		c.JSON(500, gin.H{"status": "error", "message": "Error resetting password"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "message": "Password reset successfully"})
+}
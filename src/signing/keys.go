@@ -0,0 +1,122 @@
+package signing
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// An RSA key used to sign tokens, identified by the kid header of the tokens it signs.
+type Key struct {
+	ID         string
+	PrivateKey *rsa.PrivateKey
+}
+
+// The keys tokens can be verified with. New tokens are signed with the active key,
+// while the others stay trusted until the tokens they signed have expired.
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+// A public key in JSON Web Key format.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Loads every <kid>.pem file in dir as an RSA private key. activeID names the key
+// new tokens are signed with, and may be empty when the directory holds one key.
+func LoadKeySet(dir string, activeID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	set := &KeySet{keys: map[string]*Key{}}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		set.keys[id] = &Key{ID: id, PrivateKey: privateKey}
+	}
+
+	if len(set.keys) == 0 {
+		return nil, fmt.Errorf("no signing keys found in %s", dir)
+	}
+	if activeID == "" && len(set.keys) == 1 {
+		for id := range set.keys {
+			activeID = id
+		}
+	}
+
+	active, ok := set.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q not found in %s", activeID, dir)
+	}
+	set.active = active
+
+	return set, nil
+}
+
+// Returns the key new tokens are signed with.
+func (s *KeySet) Active() *Key {
+	return s.active
+}
+
+// Returns the public key for a kid header.
+func (s *KeySet) PublicKey(id string) (*rsa.PublicKey, error) {
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return &key.PrivateKey.PublicKey, nil
+}
+
+// Returns the public keys of the set, sorted by kid.
+func (s *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range s.keys {
+		publicKey := key.PrivateKey.PublicKey
+		jwks.Keys = append(jwks.Keys, JWK{
+			KeyType:   "RSA",
+			Use:       "sig",
+			Algorithm: jwt.SigningMethodRS256.Alg(),
+			KeyID:     key.ID,
+			Modulus:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		})
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].KeyID < jwks.Keys[j].KeyID
+	})
+
+	return jwks
+}
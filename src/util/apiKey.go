@@ -0,0 +1,20 @@
+package util
+
+import "strings"
+
+// API keys start with this prefix so they can be told apart from access tokens.
+const APIKeyPrefix = "cak_"
+
+// Generates a new API key.
+func GenerateAPIKey() (string, error) {
+	token, err := GenerateRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	return APIKeyPrefix + token, nil
+}
+
+func IsAPIKey(key string) bool {
+	return strings.HasPrefix(key, APIKeyPrefix)
+}
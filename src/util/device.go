@@ -0,0 +1,79 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Describes the device a session was started from.
+type Device struct {
+	Name      string
+	IPAddress string
+	UserAgent string
+}
+
+var userAgentPlatforms = []struct{ token, name string }{
+	{"iPhone", "iPhone"},
+	{"iPad", "iPad"},
+	{"Android", "Android"},
+	{"Windows", "Windows"},
+	{"Mac OS X", "macOS"},
+	{"CrOS", "ChromeOS"},
+	{"Linux", "Linux"},
+}
+
+// Ordered so that browsers whose user agent also mentions another browser come first
+var userAgentBrowsers = []struct{ token, name string }{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Firefox/", "Firefox"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+}
+
+// Builds a readable name such as "Chrome on Windows" from a user agent.
+func DeviceNameFromUserAgent(userAgent string) string {
+	platform := ""
+	for _, candidate := range userAgentPlatforms {
+		if strings.Contains(userAgent, candidate.token) {
+			platform = candidate.name
+			break
+		}
+	}
+
+	browser := ""
+	for _, candidate := range userAgentBrowsers {
+		if strings.Contains(userAgent, candidate.token) {
+			browser = candidate.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	default:
+		return "Unknown device"
+	}
+}
+
+// Reads the device of the request. Clients can name the device with the
+// X-Device-Name header, otherwise the name is derived from the user agent.
+func DeviceFromRequest(c *gin.Context) Device {
+	userAgent := c.Request.UserAgent()
+
+	name := strings.TrimSpace(c.GetHeader("X-Device-Name"))
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+	if name == "" {
+		name = DeviceNameFromUserAgent(userAgent)
+	}
+
+	return Device{Name: name, IPAddress: c.ClientIP(), UserAgent: userAgent}
+}
@@ -0,0 +1,9 @@
+package util
+
+import "strings"
+
+// Returns the form emails are stored and looked up in, so the same address with
+// different casing belongs to a single account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -1,29 +1,94 @@
 package util
 
 import (
+	"chat-app-back/src/config"
+	"errors"
+	"fmt"
 	"os"
+	"slices"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func GenerateToken(uid string, expirationDelta int64, refresh bool) (string, error) {
-	// Get toke secret from env file
-	var tokenSecret string
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+
+	// Set on the claims of requests authenticated with a bot's API key.
+	TokenTypeAPIKey = "api_key"
+)
+
+// The claims of the access and refresh tokens we issue. UID and SessionID identify
+// the user and the refresh token family the token belongs to, and Role is the
+// user's role when the token was issued.
+type TokenClaims struct {
+	UID       string   `json:"uid"`
+	SessionID string   `json:"sid"`
+	Role      string   `json:"role,omitempty"`
+	TokenType string   `json:"token_type"`
+	Scopes    []string `json:"scopes,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Reports whether the credential is limited to its scopes. First-party logins are not.
+func (c *TokenClaims) Scoped() bool {
+	return c.TokenType == TokenTypeAPIKey || len(c.Scopes) > 0
+}
+
+// Reports whether the credential may be used for the given scope.
+func (c *TokenClaims) HasScope(scope string) bool {
+	return !c.Scoped() || slices.Contains(c.Scopes, scope)
+}
+
+var ErrInvalidTokenClaims = errors.New("invalid token claims")
+
+// The issuer and audience of our tokens, set by JWT_ISSUER and JWT_AUDIENCE.
+func tokenIssuer() string {
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "chat-app"
+}
+
+func tokenAudience() string {
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		return audience
+	}
+	return "chat-app"
+}
+
+// Signs an access or refresh token. Tokens for third-party apps carry the scopes the
+// user granted; first-party tokens have none.
+func GenerateToken(uid string, sessionID string, role string, scopes []string, expirationDelta int64, refresh bool) (string, error) {
+	tokenType := TokenTypeAccess
 	if refresh {
-		tokenSecret = os.Getenv("REFRESH_TOKEN_KEY")
-	} else {
-		tokenSecret = os.Getenv("ACCESS_TOKEN_KEY")
+		tokenType = TokenTypeRefresh
 	}
 
 	// Generate the new token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"uid": uid,
-		"exp": time.Now().Add(time.Second * time.Duration(expirationDelta)).Unix(),
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, TokenClaims{
+		UID:       uid,
+		SessionID: sessionID,
+		Role:      role,
+		TokenType: tokenType,
+		Scopes:    scopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer(),
+			Subject:   uid,
+			Audience:  jwt.ClaimStrings{tokenAudience()},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Second * time.Duration(expirationDelta))),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        primitive.NewObjectID().Hex(),
+		},
 	})
 
-	// Sign the token
-	tokenString, err := token.SignedString([]byte(tokenSecret))
+	// Sign the token with the active key and name it so verifiers can pick the public key
+	key := config.SigningKeys().Active()
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(key.PrivateKey)
 
 	if err != nil {
 		return "", err
@@ -31,3 +96,39 @@ func GenerateToken(uid string, expirationDelta int64, refresh bool) (string, err
 
 	return tokenString, nil
 }
+
+// Returns the public key a token was signed with, for use as a jwt.Keyfunc.
+// Only RS256 tokens signed by one of our keys are accepted.
+func TokenVerificationKey(token *jwt.Token) (interface{}, error) {
+	if token.Method != jwt.SigningMethodRS256 {
+		return nil, fmt.Errorf("unexpected signing method %q", token.Header["alg"])
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	return config.SigningKeys().PublicKey(kid)
+}
+
+// Verifies a token we issued and returns its claims. The token must be of the given
+// type, so a refresh token is never accepted where an access token is expected.
+func ParseToken(tokenString string, tokenType string) (*TokenClaims, error) {
+	claims := &TokenClaims{}
+	parsedToken, err := jwt.ParseWithClaims(tokenString, claims, TokenVerificationKey)
+	if err != nil {
+		return nil, err
+	}
+	if !parsedToken.Valid {
+		return nil, ErrInvalidTokenClaims
+	}
+
+	// Expiry is checked by the parser, the rest is checked here
+	if claims.ExpiresAt == nil ||
+		!claims.VerifyIssuer(tokenIssuer(), true) ||
+		!claims.VerifyAudience(tokenAudience(), true) ||
+		claims.TokenType != tokenType ||
+		claims.UID == "" ||
+		claims.ID == "" {
+		return nil, ErrInvalidTokenClaims
+	}
+
+	return claims, nil
+}
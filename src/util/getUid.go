@@ -1,27 +1,38 @@
 package util
 
 import (
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 )
 
-func GetUid(c *gin.Context) string {
+// Returns the claims of the access token the request was authenticated with.
+func GetClaims(c *gin.Context) *TokenClaims {
 	claims, exists := c.Get("claims")
 	if !exists {
-		return ""
+		return nil
 	}
 
-	// Type assertion to *jwt.MapClaims
-	claimsMap, ok := claims.(*jwt.MapClaims)
-	if !ok || claimsMap == nil {
+	tokenClaims, ok := claims.(*TokenClaims)
+	if !ok {
+		return nil
+	}
+
+	return tokenClaims
+}
+
+func GetUid(c *gin.Context) string {
+	claims := GetClaims(c)
+	if claims == nil {
 		return ""
 	}
 
-	// Dereference and type assert the uid
-	uid, uidOk := (*claimsMap)["uid"].(string)
-	if !uidOk || uid == "" {
+	return claims.UID
+}
+
+func GetSessionID(c *gin.Context) string {
+	claims := GetClaims(c)
+	if claims == nil {
 		return ""
 	}
 
-	return uid
+	return claims.SessionID
 }
@@ -0,0 +1,35 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"sync"
+)
+
+var refreshTokenHashKey []byte
+var refreshTokenHashKeyOnce sync.Once
+
+// Loads REFRESH_TOKEN_HASH_KEY, exiting when it is not set. Called at startup so a
+// missing key is noticed before the first login rather than during it.
+func RequireRefreshTokenHashKey() {
+	refreshTokenHashKeyOnce.Do(func() {
+		key := os.Getenv("REFRESH_TOKEN_HASH_KEY")
+		if key == "" {
+			log.Fatal("REFRESH_TOKEN_HASH_KEY is required")
+		}
+		refreshTokenHashKey = []byte(key)
+	})
+}
+
+// Hashes a refresh token for storage with HMAC-SHA256 keyed by REFRESH_TOKEN_HASH_KEY,
+// so a copy of the database alone cannot be turned back into usable tokens.
+func HashRefreshToken(refreshToken string) string {
+	RequireRefreshTokenHashKey()
+
+	mac := hmac.New(sha256.New, refreshTokenHashKey)
+	mac.Write([]byte(refreshToken))
+	return hex.EncodeToString(mac.Sum(nil))
+}
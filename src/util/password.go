@@ -0,0 +1,21 @@
+package util
+
+import "golang.org/x/crypto/bcrypt"
+
+const passwordHashCost = 12
+
+// bcrypt only hashes the first 72 bytes, and refuses longer passwords.
+const MaxPasswordBytes = 72
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func CheckPassword(hash string, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
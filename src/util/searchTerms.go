@@ -0,0 +1,21 @@
+package util
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Splits text into the lowercase words it can be found by. Words are NFKC folded so
+// fullwidth and other compatibility forms match their plain versions.
+func SearchTerms(text string) []string {
+	folded := strings.ToLower(norm.NFKC.String(text))
+	terms := strings.FieldsFunc(folded, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	slices.Sort(terms)
+	return slices.Compact(terms)
+}
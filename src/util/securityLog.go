@@ -0,0 +1,25 @@
+package util
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/models"
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Records a security relevant event in the security_events collection.
+func LogSecurityEvent(ctx context.Context, event models.SecurityEvent) {
+	db := config.MongoClient()
+
+	event.ID = primitive.NewObjectID()
+	event.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+
+	log.Printf("security event %s for user %s", event.Type, event.UserID)
+	_, err := db.Database("Chat-App").Collection("security_events").InsertOne(ctx, event)
+	if err != nil {
+		log.Println("error recording security event:", err)
+	}
+}
@@ -2,6 +2,8 @@ package util
 
 import (
 	"chat-app-back/src/config"
+	"chat-app-back/src/models"
+	"context"
 	"log"
 	"time"
 
@@ -12,8 +14,28 @@ import (
 
 var userTimers = make(map[string]*time.Timer)
 
-func SetOfflineAfterDuration(uid string, d time.Duration, c *gin.Context) {
+// Called with the user's new status when they come online or go offline. Set before
+// the server starts handling requests.
+var OnPresenceChange func(uid string, status string)
+
+// Sets the user's status, reporting it to OnPresenceChange if it changed.
+func setPresence(ctx context.Context, objectID primitive.ObjectID, status string) error {
 	db := config.MongoClient()
+
+	filter := bson.M{"_id": objectID, "status": bson.M{"$ne": status}}
+	update := bson.M{"$set": bson.M{"status": status}}
+	result, err := db.Database("Chat-App").Collection("users").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.ModifiedCount > 0 && OnPresenceChange != nil {
+		OnPresenceChange(objectID.Hex(), status)
+	}
+	return nil
+}
+
+func SetOfflineAfterDuration(uid string, d time.Duration, c *gin.Context) {
 	if timer, exists := userTimers[uid]; exists {
 		timer.Stop()
 	}
@@ -24,9 +46,7 @@ func SetOfflineAfterDuration(uid string, d time.Duration, c *gin.Context) {
 		log.Fatalln(err)
 		return
 	}
-	filter := bson.M{"_id": objectID}
-	update := bson.M{"$set": bson.M{"status": "online"}}
-	_, err = db.Database("Chat-App").Collection("users").UpdateOne(c, filter, update)
+	err = setPresence(c, objectID, models.StatusOnline)
 	if err != nil {
 		log.Fatalln(err)
 		return
@@ -34,11 +54,10 @@ func SetOfflineAfterDuration(uid string, d time.Duration, c *gin.Context) {
 
 	timer := time.AfterFunc(d, func() {
 		if _, exists := userTimers[uid]; exists {
-			// Change status on db
-
-			filter := bson.M{"_id": objectID}
-			update := bson.M{"$set": bson.M{"status": "offline"}}
-			db.Database("Chat-App").Collection("users").UpdateOne(c, filter, update)
+			// Change status on db. The request is over by now, so its context can't be used
+			if err := setPresence(context.Background(), objectID, models.StatusOffline); err != nil {
+				log.Println(err.Error())
+			}
 		}
 	})
 	userTimers[uid] = timer
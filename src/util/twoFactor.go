@@ -0,0 +1,70 @@
+package util
+
+import (
+	"chat-app-back/src/config"
+	"chat-app-back/src/models"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const recoveryCodeCount = 10
+
+// Generates one-time recovery codes, returning them along with the hashes to store.
+func GenerateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		bytes := make([]byte, 10)
+		if _, err := rand.Read(bytes); err != nil {
+			return nil, nil, err
+		}
+
+		encoded := strings.ToLower(base32.StdEncoding.EncodeToString(bytes))
+		code := encoded[0:4] + "-" + encoded[4:8] + "-" + encoded[8:12] + "-" + encoded[12:16]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+func HashRecoveryCode(code string) string {
+	return HashToken(strings.ToLower(strings.TrimSpace(code)))
+}
+
+// Checks a TOTP code or a recovery code for a user with two-factor authentication
+// enabled. Accepted TOTP codes and recovery codes are consumed atomically, so each
+// one works only once.
+func VerifySecondFactor(ctx context.Context, user models.User, code string, recoveryCode string) bool {
+	db := config.MongoClient()
+
+	if !user.TOTPEnabled {
+		return false
+	}
+
+	if code != "" {
+		step, ok := ValidateTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false
+		}
+
+		filter := bson.M{"_id": user.ID, "totp_last_step": bson.M{"$not": bson.M{"$gte": step}}}
+		result, err := db.Database("Chat-App").Collection("users").UpdateOne(ctx, filter, bson.M{"$set": bson.M{"totp_last_step": step}})
+		return err == nil && result.ModifiedCount == 1
+	}
+
+	if recoveryCode != "" {
+		hash := HashRecoveryCode(recoveryCode)
+		filter := bson.M{"_id": user.ID, "recovery_codes": hash}
+		result, err := db.Database("Chat-App").Collection("users").UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"recovery_codes": hash}})
+		return err == nil && result.ModifiedCount == 1
+	}
+
+	return false
+}
@@ -0,0 +1,155 @@
+package util
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/secure/precis"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 32
+)
+
+var (
+	ErrUsernameLength     = errors.New("username must be between 3 and 32 characters")
+	ErrUsernameCharacters = errors.New("username may only contain letters, numbers, '_', '.' and '-', and must start and end with a letter or number")
+	ErrUsernameScript     = errors.New("username must not mix letters from different scripts")
+	ErrUsernameReserved   = errors.New("username is reserved")
+)
+
+// Names nobody can take, compared by their UsernameKey.
+var reservedUsernames = []string{
+	"admin", "administrator", "api", "chat-app", "deleted", "everyone", "help", "here",
+	"me", "mod", "moderator", "null", "official", "root", "security", "staff",
+	"support", "system", "undefined",
+}
+
+// Letters and digits that look alike, mapped to the character they are mistaken for.
+// Keys are lowercase, so i and l are folded together to catch I and l.
+// It covers the common Latin, Cyrillic and Greek lookalikes rather than the full
+// Unicode confusables table.
+var confusables = map[rune]string{
+	'0': "o", '1': "l", 'i': "l", '|': "l",
+	'а': "a", 'с': "c", 'е': "e", 'һ': "h", 'і': "l", 'ј': "j", 'ӏ': "l",
+	'о': "o", 'р': "p", 'ԛ': "q", 'ѕ': "s", 'ԝ': "w", 'х': "x", 'у': "y", 'ԁ': "d",
+	'α': "a", 'ϲ': "c", 'ι': "l", 'κ': "k", 'ν': "v", 'ο': "o", 'ρ': "p", 'υ': "u", 'χ': "x",
+	'.': "_", '-': "_",
+}
+
+// Sequences that look like a single letter, replaced after single characters.
+var confusableSequences = strings.NewReplacer("rn", "m", "vv", "w", "cl", "d")
+
+// Scripts that are commonly written together in one name.
+var compatibleScripts = map[string]string{
+	"Hiragana": "Han",
+	"Katakana": "Han",
+}
+
+var commonScripts = []string{"Latin", "Cyrillic", "Greek", "Arabic", "Hebrew", "Han", "Hiragana", "Katakana", "Hangul", "Devanagari", "Thai"}
+
+func runeScript(r rune) string {
+	for _, name := range commonScripts {
+		if unicode.Is(unicode.Scripts[name], r) {
+			return name
+		}
+	}
+	for name, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			return name
+		}
+	}
+	return ""
+}
+
+func isUsernameSeparator(r rune) bool {
+	return r == '_' || r == '.' || r == '-'
+}
+
+// Validates a username and returns it normalized for display. Usernames are
+// letters and digits of a single script, with '_', '.' and '-' in between.
+func NormalizeUsername(username string) (string, error) {
+	username = norm.NFC.String(strings.TrimSpace(username))
+
+	length := utf8.RuneCountInString(username)
+	if length < MinUsernameLength || length > MaxUsernameLength {
+		return "", ErrUsernameLength
+	}
+
+	// Maps fullwidth forms and rejects invisible and other characters that are unsafe
+	// in identifiers
+	username, err := precis.UsernameCasePreserved.String(username)
+	if err != nil {
+		return "", ErrUsernameCharacters
+	}
+
+	script := ""
+	runes := []rune(username)
+	for i, r := range runes {
+		if isUsernameSeparator(r) {
+			if i == 0 || i == len(runes)-1 {
+				return "", ErrUsernameCharacters
+			}
+			continue
+		}
+		if unicode.IsDigit(r) {
+			continue
+		}
+		if !unicode.IsLetter(r) {
+			return "", ErrUsernameCharacters
+		}
+
+		letterScript := runeScript(r)
+		if compatible, ok := compatibleScripts[letterScript]; ok {
+			letterScript = compatible
+		}
+		if script != "" && letterScript != script {
+			return "", ErrUsernameScript
+		}
+		script = letterScript
+	}
+
+	if IsReservedUsername(username) {
+		return "", ErrUsernameReserved
+	}
+
+	return username, nil
+}
+
+// Returns the key usernames are compared by. Names that differ only in case,
+// width, separators or lookalike characters have the same key.
+func UsernameKey(username string) string {
+	folded := strings.ToLower(norm.NFKC.String(strings.TrimSpace(username)))
+
+	var builder strings.Builder
+	for _, r := range folded {
+		if replacement, ok := confusables[r]; ok {
+			builder.WriteString(replacement)
+		} else {
+			builder.WriteRune(r)
+		}
+	}
+
+	return confusableSequences.Replace(builder.String())
+}
+
+// Returns the form usernames are searched by. Unlike UsernameKey it only folds case
+// and width, so a prefix of the key is a prefix of the name as it was typed.
+func UsernameSearchKey(username string) string {
+	return strings.ToLower(norm.NFKC.String(strings.TrimSpace(username)))
+}
+
+func IsReservedUsername(username string) bool {
+	key := UsernameKey(username)
+	for _, reserved := range reservedUsernames {
+		if key == UsernameKey(reserved) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,84 @@
+package util
+
+import (
+	"chat-app-back/src/config"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	UsernameChangeCooldown = 30 * 24 * time.Hour
+
+	// How long a previous username stays reserved for the user who had it
+	PreviousUsernameHoldPeriod = 180 * 24 * time.Hour
+)
+
+var ErrUsernameTaken = errors.New("username already taken")
+
+// Checks that no other user has the username, or had it recently. The unique index on
+// username_key is what finally prevents two users from taking the same name at once.
+func CheckUsernameAvailable(ctx context.Context, key string, uid string) error {
+	db := config.MongoClient()
+
+	filter := bson.M{"username_key": key}
+	if objectID, err := primitive.ObjectIDFromHex(uid); err == nil {
+		filter["_id"] = bson.M{"$ne": objectID}
+	}
+	count, err := db.Database("Chat-App").Collection("users").CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUsernameTaken
+	}
+
+	held := bson.M{
+		"username_key": key,
+		"user_id":      bson.M{"$ne": uid},
+		"changed_at":   bson.M{"$gt": primitive.NewDateTimeFromTime(time.Now().Add(-PreviousUsernameHoldPeriod))},
+	}
+	count, err = db.Database("Chat-App").Collection("username_history").CountDocuments(ctx, held, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUsernameTaken
+	}
+
+	return nil
+}
+
+// Validates a requested username and checks that it is free for the user, returning
+// the username to store and its key. uid is empty for users not created yet.
+func PrepareUsername(ctx context.Context, requested string, uid string) (string, string, error) {
+	username, err := NormalizeUsername(requested)
+	if err != nil {
+		return "", "", err
+	}
+
+	key := UsernameKey(username)
+	if err := CheckUsernameAvailable(ctx, key, uid); err != nil {
+		return "", "", err
+	}
+
+	return username, key, nil
+}
+
+// Responds with the error from PrepareUsername. Errors about the username are shown
+// as they are, others respond with the failure message.
+func RespondWithUsernameError(c *gin.Context, err error, failureMessage string) {
+	switch err {
+	case ErrUsernameLength, ErrUsernameCharacters, ErrUsernameScript, ErrUsernameReserved:
+		c.JSON(400, gin.H{"status": "error", "message": err.Error()})
+	case ErrUsernameTaken:
+		c.JSON(409, gin.H{"status": "error", "message": err.Error()})
+	default:
+		c.JSON(500, gin.H{"status": "error", "message": failureMessage})
+	}
+}